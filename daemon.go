@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+const (
+	defaultDaemonInterval       = "24h"
+	defaultMaxDeletionsPerCycle = 1000
+)
+
+// A policyFile lists the retention policies applied by -daemon, and how often they are applied.
+type policyFile struct {
+	Interval             string
+	MaxDeletionsPerCycle int
+	Policies             []retentionPolicy
+
+	interval time.Duration
+}
+
+// A retentionPolicy wipes the selected kinds of items in the given conversations once they are older than MaxAge.
+type retentionPolicy struct {
+	Name      string
+	Channels  []string // channel names or glob patterns
+	IMs       []string // the other participants of an IM (or group IM), comma-separated
+	Messages  bool
+	Files     bool
+	Reactions bool
+	Pins      bool
+	Stars     bool
+	MaxAge    string
+
+	maxAge time.Duration
+}
+
+func readPolicyFile(path string) (*policyFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var p policyFile
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse: %v", err)
+	}
+	if p.Interval == "" {
+		p.Interval = defaultDaemonInterval
+	}
+	if p.interval, err = parseAge(p.Interval); err != nil {
+		return nil, fmt.Errorf("Interval: %v", err)
+	}
+	if p.MaxDeletionsPerCycle == 0 {
+		p.MaxDeletionsPerCycle = defaultMaxDeletionsPerCycle
+	}
+	if p.MaxDeletionsPerCycle < 0 {
+		return nil, fmt.Errorf("MaxDeletionsPerCycle: must be positive")
+	}
+	if len(p.Policies) == 0 {
+		return nil, fmt.Errorf("no policies")
+	}
+	for i := range p.Policies {
+		r := &p.Policies[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("policy %d", i+1)
+		}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", r.Name, err)
+		}
+	}
+	return &p, nil
+}
+
+func (r *retentionPolicy) validate() error {
+	if r.MaxAge == "" {
+		return fmt.Errorf("MaxAge is required")
+	}
+	maxAge, err := parseAge(r.MaxAge)
+	if err != nil {
+		return fmt.Errorf("MaxAge: %v", err)
+	}
+	r.maxAge = maxAge
+	if !r.Messages && !r.Files && !r.Reactions && !r.Pins && !r.Stars {
+		return fmt.Errorf("nothing to wipe (set Messages, Files, Reactions, Pins or Stars)")
+	}
+	if len(r.Channels) == 0 && len(r.IMs) == 0 {
+		return fmt.Errorf("no conversations (set Channels or IMs)")
+	}
+	for i, p := range r.Channels {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "#")
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("Channels: invalid pattern %q: %v", p, err)
+		}
+		r.Channels[i] = p
+	}
+	return nil
+}
+
+func (r *retentionPolicy) targets() wipe.Targets {
+	return wipe.Targets{
+		Messages:  r.Messages,
+		Files:     r.Files,
+		Reactions: r.Reactions,
+		Pins:      r.Pins,
+		Stars:     r.Stars,
+	}
+}
+
+// discardProgress is the progress of unattended runs, which only log their results.
+type discardProgress struct{}
+
+func (discardProgress) Add(int) error { return nil }
+
+// daemon applies the retention policies every interval, until ctx is done.
+func daemon(ctx context.Context, policies *policyFile) {
+	state.Progress = discardProgress{}
+	log.Printf("daemon: applying %d policies every %v (at most %d deletions per cycle)", len(policies.Policies), policies.interval, policies.MaxDeletionsPerCycle)
+	for cycle := 1; ; cycle++ {
+		runCycle(ctx, cycle, policies)
+		if ctx.Err() != nil {
+			log.Print("daemon: stopped")
+			return
+		}
+		log.Printf("cycle %d: next cycle at %s", cycle, time.Now().Add(policies.interval).Local().Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			log.Print("daemon: stopped")
+			return
+		case <-time.After(policies.interval):
+		}
+	}
+}
+
+// runCycle applies each policy once. Once the cycle's deletion budget is used up, the remaining items are left for later cycles.
+func runCycle(ctx context.Context, cycle int, policies *policyFile) {
+	start := time.Now()
+	state.Started = start.UTC()
+	state.Results = newResultCollector()
+	state.RateLimits.reset()
+	budget := policies.MaxDeletionsPerCycle
+	wiped, failed, postponed := 0, 0, 0
+	log.Printf("cycle %d: started", cycle)
+	for i := range policies.Policies {
+		p := &policies.Policies[i]
+		if ctx.Err() != nil {
+			break
+		}
+		w, groups, err := policyItems(ctx, p, start)
+		if err != nil {
+			log.Printf("cycle %d: %s: %v", cycle, p.Name, err)
+			continue
+		}
+		n := 0
+		for j, group := range groups {
+			if len(group) > budget {
+				postponed += len(group) - budget
+				groups[j] = group[:budget]
+			}
+			budget -= len(groups[j])
+			n += len(groups[j])
+		}
+		log.Printf("cycle %d: %s: wiping %d items older than %s", cycle, p.Name, n, p.MaxAge)
+		for j, group := range groups {
+			if len(group) == 0 {
+				continue
+			}
+			source := make(chan wipe.Item, len(group))
+			for _, item := range group {
+				source <- item
+			}
+			close(source)
+			done, groupFailed := w.Run(ctx, source)
+			wiped += done
+			failed += groupFailed
+			if groupFailed > 0 {
+				log.Printf("cycle %d: %s: %s %ss: %d failed", cycle, p.Name, wipe.Steps[j].Action, wipe.Steps[j].Kind, groupFailed)
+			}
+		}
+	}
+	state.Results.printChannels()
+	state.Results.printFailures()
+	log.Printf("cycle %d: done in %v: %d wiped, %d failed", cycle, time.Since(start).Round(time.Second), wiped, failed)
+	if postponed > 0 {
+		log.Printf("cycle %d: reached the limit of %d deletions per cycle; %d items are left for the next cycles", cycle, policies.MaxDeletionsPerCycle, postponed)
+	}
+}
+
+// policyItems lists the items the policy wipes (as of now), grouped by wipe step, and the Wiper that wipes them.
+func policyItems(ctx context.Context, p *retentionPolicy, now time.Time) (*wipe.Wiper, [][]wipe.Item, error) {
+	w := state.Wiper.With(wipe.WithTargets(p.targets()), wipe.WithDateRange(time.Time{}, now.Add(-p.maxAge)))
+	channels, err := policyConversations(ctx, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []wipe.Item
+	for _, c := range channels {
+		resolved(c)
+		channelItems, err := w.Items(ctx, c)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list items in %s: %v", c.Name, err)
+		}
+		items = append(items, channelItems...)
+	}
+	return w, groupItems(dedupeFileItems(items)), nil
+}
+
+// policyConversations resolves the conversations of the policy.
+func policyConversations(ctx context.Context, p *retentionPolicy) ([]slack.Channel, error) {
+	var channels []slack.Channel
+	if len(p.Channels) > 0 {
+		resolved, err := state.Wiper.ResolveChannels(ctx, p.Channels, false)
+		if err != nil {
+			return nil, fmt.Errorf("resolve channels %q: %v", p.Channels, err)
+		}
+		channels = append(channels, resolved...)
+	}
+	if len(p.IMs) > 0 {
+		if _, err := state.Wiper.Users(ctx); err != nil {
+			return nil, fmt.Errorf("fetch users: %v", err)
+		}
+	}
+	for _, im := range p.IMs {
+		var names, ids []string
+		for _, name := range strings.Split(im, ",") {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+			u, ok := state.Wiper.UserByName(name)
+			if !ok {
+				return nil, fmt.Errorf("IMs: user not found: %q", name)
+			}
+			names = append(names, name)
+			ids = append(ids, u.ID)
+		}
+		c, err := state.Wiper.ResolveIM(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("resolve IM with %v: %v", names, err)
+		}
+		c.Name = fmt.Sprintf("IM with %v", names)
+		channels = append(channels, c)
+	}
+	return channels, nil
+}
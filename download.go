@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/schollz/progressbar"
+)
+
+const manifestName = "manifest.json"
+
+type manifestEntry struct {
+	ID       string
+	Name     string
+	Mimetype string
+	Size     int64
+	SHA256   string
+	Path     string
+	Channels []string
+}
+
+// downloadFiles downloads all state.UserFiles into dir, records them in the manifest there,
+// and returns the IDs of the files whose local copy matches the manifest.
+func downloadFiles(dir string) (map[string]bool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %v", err)
+	}
+	bar := progressbar.NewOptions(len(state.UserFiles), progressbar.OptionSetDescription("downloading files"), progressbar.OptionSetWriter(out))
+	bar.RenderBlank()
+	for _, f := range state.UserFiles {
+		bar.Add(1)
+		entry, err := downloadFile(dir, f)
+		if err != nil {
+			log.Printf("download file %s (%q): %v", f.ID, f.Name, err)
+			continue
+		}
+		manifest[f.ID] = entry
+	}
+	bar.Finish()
+	fmt.Fprintln(out)
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, fmt.Errorf("write manifest: %v", err)
+	}
+	verified := make(map[string]bool, len(manifest))
+	for _, f := range state.UserFiles {
+		entry, ok := manifest[f.ID]
+		if !ok {
+			continue
+		}
+		if err := verifyDownload(dir, entry); err != nil {
+			log.Printf("verify file %s (%q): %v", f.ID, f.Name, err)
+			continue
+		}
+		verified[f.ID] = true
+	}
+	return verified, nil
+}
+
+func downloadFile(dir string, f slack.File) (manifestEntry, error) {
+	entry := manifestEntry{
+		ID:       f.ID,
+		Name:     f.Name,
+		Mimetype: f.Mimetype,
+		Path:     f.ID + "-" + safeFileName(f.Name),
+	}
+	entry.Channels = append(entry.Channels, f.Channels...)
+	entry.Channels = append(entry.Channels, f.Groups...)
+	entry.Channels = append(entry.Channels, f.IMs...)
+	url := f.URLPrivateDownload
+	if url == "" {
+		url = f.URLPrivate
+	}
+	if f.IsExternal || url == "" {
+		return entry, fmt.Errorf("no downloadable URL")
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return entry, err
+	}
+	req.Header.Set("Authorization", "Bearer "+config.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return entry, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return entry, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	out, err := os.OpenFile(filepath.Join(dir, entry.Path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return entry, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, h), resp.Body)
+	if err != nil {
+		out.Close()
+		return entry, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return entry, err
+	}
+	if err := out.Close(); err != nil {
+		return entry, err
+	}
+	if f.Size > 0 && n != int64(f.Size) {
+		return entry, fmt.Errorf("downloaded %d bytes, expected %d", n, f.Size)
+	}
+	entry.Size = n
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return entry, nil
+}
+
+// verifyDownload re-reads the local copy and checks it against its manifest entry.
+func verifyDownload(dir string, entry manifestEntry) error {
+	f, err := os.Open(filepath.Join(dir, entry.Path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if n != entry.Size {
+		return fmt.Errorf("local copy has %d bytes, manifest says %d", n, entry.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != entry.SHA256 {
+		return fmt.Errorf("local copy has SHA-256 %s, manifest says %s", sum, entry.SHA256)
+	}
+	return nil
+}
+
+func readManifest(dir string) (map[string]manifestEntry, error) {
+	manifest := make(map[string]manifestEntry)
+	f, err := os.Open(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []manifestEntry
+	if err := json.NewDecoder(f).Decode(&entries); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		manifest[e.ID] = e
+	}
+	return manifest, nil
+}
+
+func writeManifest(dir string, manifest map[string]manifestEntry) error {
+	entries := make([]manifestEntry, 0, len(manifest))
+	for _, e := range manifest {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	path := filepath.Join(dir, manifestName)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
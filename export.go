@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+type exportedMessage struct {
+	Channel     string
+	ChannelName string
+	Timestamp   string
+	Time        time.Time
+	User        string
+	UserName    string
+	Text        string
+	Mentions    map[string]string  `json:",omitempty"`
+	Attachments []slack.Attachment `json:",omitempty"`
+	Permalink   string             `json:",omitempty"`
+}
+
+var mentionPattern = regexp.MustCompile(`<@([UW][A-Z0-9]+)(?:\|[^>]*)?>`)
+
+// exportMessages writes state.UserMessages as JSON lines to a new file in dir and returns its path.
+func exportMessages(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s.jsonl", state.Channel.ID, time.Now().Format("20060102T150405"))
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	enc := json.NewEncoder(f)
+	for _, m := range state.UserMessages {
+		if err := enc.Encode(exportMessage(m)); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+func exportMessage(m slack.SearchMessage) exportedMessage {
+	e := exportedMessage{
+		Channel:     m.Channel.ID,
+		ChannelName: m.Channel.Name,
+		Timestamp:   m.Timestamp,
+		Time:        wipe.TimestampTime(m.Timestamp),
+		User:        m.User,
+		UserName:    state.Wiper.UserName(m.User),
+		Text:        m.Text,
+		Attachments: m.Attachments,
+		Permalink:   m.Permalink,
+	}
+	if e.UserName == "" {
+		e.UserName = m.Username
+	}
+	for _, match := range mentionPattern.FindAllStringSubmatch(m.Text, -1) {
+		if e.Mentions == nil {
+			e.Mentions = make(map[string]string)
+		}
+		e.Mentions[match[1]] = state.Wiper.UserName(match[1])
+	}
+	return e
+}
@@ -0,0 +1,371 @@
+package fakeslack
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func (s *Server) authTest(url.Values) (response, error) {
+	u, ok := s.user(s.self)
+	if !ok {
+		return nil, apiError("invalid_auth")
+	}
+	return response{
+		"url":     s.URL + "/",
+		"team":    "sandbox",
+		"team_id": "T00000000",
+		"user":    u.Name,
+		"user_id": u.ID,
+	}, nil
+}
+
+func (s *Server) usersList(form url.Values) (response, error) {
+	from, to, next := cursorPage(form, len(s.users), 200)
+	return response{
+		"members":           s.users[from:to],
+		"response_metadata": slack.ResponseMetadata{Cursor: next},
+	}, nil
+}
+
+func (s *Server) conversationsList(form url.Values) (response, error) {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(form.Get("types"), ",") {
+		types[t] = true
+	}
+	if len(types) == 1 && types[""] {
+		types["public_channel"] = true
+	}
+	excludeArchived := form.Get("exclude_archived") == "true"
+	var channels []slack.Channel
+	for _, c := range s.conversations {
+		var t string
+		switch {
+		case c.IsIM:
+			t = "im"
+		case c.IsMpIM:
+			t = "mpim"
+		case c.IsPrivate:
+			t = "private_channel"
+		default:
+			t = "public_channel"
+		}
+		if !types[t] || (excludeArchived && c.IsArchived) {
+			continue
+		}
+		if t != "public_channel" && !s.isMember(c) {
+			continue
+		}
+		channel := c.Channel
+		channel.IsMember = s.isMember(c)
+		channel.Members = nil
+		channels = append(channels, channel)
+	}
+	from, to, next := cursorPage(form, len(channels), 100)
+	return response{
+		"channels":          channels[from:to],
+		"response_metadata": slack.ResponseMetadata{Cursor: next},
+	}, nil
+}
+
+func (s *Server) conversationsMembers(form url.Values) (response, error) {
+	c, err := s.conversation(form.Get("channel"))
+	if err != nil {
+		return nil, err
+	}
+	from, to, next := cursorPage(form, len(c.Members), 100)
+	return response{
+		"members":           c.Members[from:to],
+		"response_metadata": slack.ResponseMetadata{Cursor: next},
+	}, nil
+}
+
+// inRange reports whether ts lies between the oldest and latest parameters.
+func inRange(form url.Values, ts string) bool {
+	t := parseTimestamp(ts)
+	inclusive := form.Get("inclusive") == "1" || form.Get("inclusive") == "true"
+	if oldest := form.Get("oldest"); oldest != "" {
+		o := parseTimestamp(oldest)
+		if t < o || (t == o && !inclusive) {
+			return false
+		}
+	}
+	if latest := form.Get("latest"); latest != "" {
+		l := parseTimestamp(latest)
+		if t > l || (t == l && !inclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// withReplyCount returns a copy of m with its thread reply count filled in.
+func (c *conversation) withReplyCount(m *slack.Message) slack.Message {
+	msg := *m
+	msg.ReplyCount = 0
+	for _, r := range c.messages {
+		if r.ThreadTimestamp == m.Timestamp && r.Timestamp != m.Timestamp {
+			msg.ReplyCount++
+		}
+	}
+	return msg
+}
+
+func (s *Server) conversationsHistory(form url.Values) (response, error) {
+	c, err := s.conversation(form.Get("channel"))
+	if err != nil {
+		return nil, err
+	}
+	var messages []slack.Message
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		m := c.messages[i]
+		isReply := m.ThreadTimestamp != "" && m.ThreadTimestamp != m.Timestamp && m.SubType != "thread_broadcast"
+		if isReply || !inRange(form, m.Timestamp) {
+			continue
+		}
+		messages = append(messages, c.withReplyCount(m))
+	}
+	from, to, next := cursorPage(form, len(messages), 100)
+	return response{
+		"messages":          messages[from:to],
+		"has_more":          next != "",
+		"response_metadata": slack.ResponseMetadata{Cursor: next},
+	}, nil
+}
+
+func (s *Server) conversationsReplies(form url.Values) (response, error) {
+	c, err := s.conversation(form.Get("channel"))
+	if err != nil {
+		return nil, err
+	}
+	ts := form.Get("ts")
+	parent := c.message(ts)
+	if parent == nil {
+		return nil, apiError("thread_not_found")
+	}
+	messages := []slack.Message{c.withReplyCount(parent)}
+	for _, m := range c.messages {
+		if m.ThreadTimestamp == ts && m.Timestamp != ts && inRange(form, m.Timestamp) {
+			messages = append(messages, *m)
+		}
+	}
+	from, to, next := cursorPage(form, len(messages), 100)
+	return response{
+		"messages":          messages[from:to],
+		"has_more":          next != "",
+		"response_metadata": slack.ResponseMetadata{Cursor: next},
+	}, nil
+}
+
+var searchModifier = regexp.MustCompile(`^(in|from|after|before|on):(.+)$`)
+
+// searchMessages supports queries made of in:#channel, from:@user, after:, before: and on: modifiers, plus plain words.
+// Results are sorted newest first.
+func (s *Server) searchMessages(form url.Values) (response, error) {
+	var channel, user string
+	var after, before time.Time
+	var words []string
+	for _, term := range strings.Fields(form.Get("query")) {
+		m := searchModifier.FindStringSubmatch(term)
+		if m == nil {
+			words = append(words, strings.ToLower(term))
+			continue
+		}
+		switch m[1] {
+		case "in":
+			channel = strings.TrimPrefix(m[2], "#")
+		case "from":
+			u, ok := s.user(strings.TrimPrefix(m[2], "@"))
+			if !ok {
+				return nil, apiError("invalid_query")
+			}
+			user = u.ID
+		default:
+			day, err := time.Parse("2006-01-02", m[2])
+			if err != nil {
+				return nil, apiError("invalid_query")
+			}
+			switch m[1] {
+			case "after":
+				after = day.AddDate(0, 0, 1)
+			case "before":
+				before = day
+			case "on":
+				after, before = day, day.AddDate(0, 0, 1)
+			}
+		}
+	}
+	var matches []slack.SearchMessage
+	for _, c := range s.conversations {
+		if c.IsIM || c.IsMpIM || (channel != "" && c.Name != channel) || (c.IsPrivate && !s.isMember(c)) {
+			continue
+		}
+	messages:
+		for _, m := range c.messages {
+			t := time.Unix(int64(parseTimestamp(m.Timestamp)), 0)
+			if (user != "" && m.User != user) || (!after.IsZero() && t.Before(after)) || (!before.IsZero() && !t.Before(before)) {
+				continue
+			}
+			for _, w := range words {
+				if !strings.Contains(strings.ToLower(m.Text), w) {
+					continue messages
+				}
+			}
+			matches = append(matches, slack.SearchMessage{
+				Type:        "message",
+				Channel:     slack.CtxChannel{ID: c.ID, Name: c.Name},
+				User:        m.User,
+				Username:    s.userName(m.User),
+				Timestamp:   m.Timestamp,
+				Text:        m.Text,
+				Permalink:   s.URL + "/archives/" + c.ID + "/p" + strings.Replace(m.Timestamp, ".", "", 1),
+				Attachments: m.Attachments,
+			})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return parseTimestamp(matches[i].Timestamp) > parseTimestamp(matches[j].Timestamp)
+	})
+	count := intParam(form, "count", slack.DEFAULT_SEARCH_COUNT)
+	page := intParam(form, "page", 1)
+	pages := (len(matches) + count - 1) / count
+	from, to := (page-1)*count, page*count
+	if from > len(matches) {
+		from = len(matches)
+	}
+	if to > len(matches) {
+		to = len(matches)
+	}
+	return response{
+		"query": form.Get("query"),
+		"messages": slack.SearchMessages{
+			Matches:    matches[from:to],
+			Paging:     slack.Paging{Count: count, Total: len(matches), Page: page, Pages: pages},
+			Pagination: slack.Pagination{TotalCount: len(matches), Page: page, PerPage: count, PageCount: pages, First: from + 1, Last: to},
+			Total:      len(matches),
+		},
+	}, nil
+}
+
+func (s *Server) userName(id string) string {
+	u, _ := s.user(id)
+	return u.Name
+}
+
+func (s *Server) filesList(form url.Values) (response, error) {
+	user, channel := form.Get("user"), form.Get("channel")
+	from, to := intParam(form, "ts_from", 0), intParam(form, "ts_to", 0)
+	var files []slack.File
+	for i := len(s.files) - 1; i >= 0; i-- {
+		f := s.files[i].File
+		switch {
+		case user != "" && f.User != user:
+		case channel != "" && !fileInChannel(f, channel):
+		case from > 0 && int(f.Created) < from:
+		case to > 0 && int(f.Created) > to:
+		default:
+			files = append(files, f)
+		}
+	}
+	count := intParam(form, "count", slack.DEFAULT_FILES_COUNT)
+	page := intParam(form, "page", 1)
+	pages := (len(files) + count - 1) / count
+	start, end := (page-1)*count, page*count
+	if start > len(files) {
+		start = len(files)
+	}
+	if end > len(files) {
+		end = len(files)
+	}
+	return response{
+		"files":  files[start:end],
+		"paging": slack.Paging{Count: count, Total: len(files), Page: page, Pages: pages},
+	}, nil
+}
+
+func fileInChannel(f slack.File, channel string) bool {
+	for _, ids := range [][]string{f.Channels, f.Groups, f.IMs} {
+		for _, id := range ids {
+			if id == channel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) filesDelete(form url.Values) (response, error) {
+	id := form.Get("file")
+	for i, f := range s.files {
+		if f.ID != id {
+			continue
+		}
+		if f.User != s.self {
+			return nil, apiError("cant_delete_file")
+		}
+		s.files = append(s.files[:i], s.files[i+1:]...)
+		return response{}, nil
+	}
+	return nil, apiError("file_not_found")
+}
+
+// ownMessage returns the message identified by the channel and ts parameters, if it was posted by the token's user.
+func (s *Server) ownMessage(form url.Values, notOwn string) (*conversation, *slack.Message, error) {
+	c, err := s.conversation(form.Get("channel"))
+	if err != nil {
+		return nil, nil, err
+	}
+	m := c.message(form.Get("ts"))
+	if m == nil {
+		return nil, nil, apiError("message_not_found")
+	}
+	if m.User != s.self {
+		return nil, nil, apiError(notOwn)
+	}
+	return c, m, nil
+}
+
+func (s *Server) chatDelete(form url.Values) (response, error) {
+	c, m, err := s.ownMessage(form, "cant_delete_message")
+	if err != nil {
+		return nil, err
+	}
+	for i, other := range c.messages {
+		if other == m {
+			c.messages = append(c.messages[:i], c.messages[i+1:]...)
+			break
+		}
+	}
+	return response{"channel": c.ID, "ts": m.Timestamp}, nil
+}
+
+func (s *Server) chatUpdate(form url.Values) (response, error) {
+	c, m, err := s.ownMessage(form, "cant_update_message")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := form["text"]; ok {
+		m.Text = form.Get("text")
+	}
+	if attachments := form.Get("attachments"); attachments != "" {
+		var a []slack.Attachment
+		if err := json.Unmarshal([]byte(attachments), &a); err != nil {
+			return nil, apiError("invalid_attachments")
+		}
+		m.Attachments = a
+	}
+	if blocks := form.Get("blocks"); blocks != "" {
+		var b []json.RawMessage
+		if err := json.Unmarshal([]byte(blocks), &b); err != nil {
+			return nil, apiError("invalid_blocks")
+		}
+	}
+	s.clock = s.clock.Add(time.Second)
+	m.Edited = &slack.Edited{User: s.self, Timestamp: timestamp(c, s.clock)}
+	return response{"channel": c.ID, "ts": m.Timestamp, "text": m.Text}, nil
+}
@@ -0,0 +1,272 @@
+// Package fakeslack is an in-memory fake of the parts of the Slack Web API that slack-wipe calls.
+//
+// Point the slack client at it by setting slack.SLACK_API to the server's APIURL.
+// Faults (rate limits and error responses) can be injected per method.
+package fakeslack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Server is a fake Slack workspace served over HTTP.
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	token         string
+	self          string
+	users         []slack.User
+	conversations []*conversation
+	files         []*file
+	clock         time.Time
+	faults        map[string][]fault
+	calls         map[string]int
+}
+
+type conversation struct {
+	slack.Channel
+	messages []*slack.Message // in timestamp order
+}
+
+type file struct {
+	slack.File
+	content []byte
+}
+
+type fault struct {
+	code       string
+	retryAfter time.Duration
+}
+
+type handler func(s *Server, form url.Values) (response, error)
+
+type response map[string]interface{}
+
+// apiError is a Slack error code, returned as {"ok": false, "error": code}.
+type apiError string
+
+func (e apiError) Error() string { return string(e) }
+
+var handlers = map[string]handler{
+	"auth.test":             (*Server).authTest,
+	"users.list":            (*Server).usersList,
+	"conversations.list":    (*Server).conversationsList,
+	"conversations.history": (*Server).conversationsHistory,
+	"conversations.replies": (*Server).conversationsReplies,
+	"conversations.members": (*Server).conversationsMembers,
+	"search.messages":       (*Server).searchMessages,
+	"files.list":            (*Server).filesList,
+	"files.delete":          (*Server).filesDelete,
+	"chat.delete":           (*Server).chatDelete,
+	"chat.update":           (*Server).chatUpdate,
+	"reactions.list":        (*Server).emptyItems,
+	"pins.list":             (*Server).emptyItems,
+	"stars.list":            (*Server).emptyItems,
+	"reactions.remove":      failWith("no_reaction"),
+	"pins.remove":           failWith("not_pinned"),
+	"stars.remove":          failWith("not_starred"),
+}
+
+// New starts an empty workspace, accepting the given token for the user with the given ID.
+func New(token, userID string) *Server {
+	s := &Server{
+		token:  token,
+		self:   userID,
+		clock:  time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		faults: make(map[string][]fault),
+		calls:  make(map[string]int),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// APIURL is the value for slack.SLACK_API.
+func (s *Server) APIURL() string {
+	return s.URL + "/api/"
+}
+
+// Fail makes the next n calls of method fail with the given Slack error code.
+func (s *Server) Fail(method, code string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.faults[method] = append(s.faults[method], fault{code: code})
+	}
+}
+
+// RateLimit makes the next n calls of method respond with HTTP 429 and the given Retry-After delay.
+func (s *Server) RateLimit(method string, retryAfter time.Duration, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.faults[method] = append(s.faults[method], fault{retryAfter: retryAfter})
+	}
+}
+
+// Calls returns the number of calls of method so far.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/files/") {
+		s.serveFile(w, r)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+	if faults := s.faults[method]; len(faults) > 0 {
+		f := faults[0]
+		s.faults[method] = faults[1:]
+		if f.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((f.retryAfter+time.Second-1)/time.Second)))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		writeJSON(w, response{"ok": false, "error": f.code})
+		return
+	}
+	if !s.authorized(r) {
+		writeJSON(w, response{"ok": false, "error": "invalid_auth"})
+		return
+	}
+	h, ok := handlers[method]
+	if !ok {
+		writeJSON(w, response{"ok": false, "error": "unknown_method"})
+		return
+	}
+	resp, err := h(s, r.Form)
+	if err != nil {
+		writeJSON(w, response{"ok": false, "error": err.Error()})
+		return
+	}
+	resp["ok"] = true
+	writeJSON(w, resp)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	return r.Form.Get("token") == s.token || r.Header.Get("Authorization") == "Bearer "+s.token
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.authorized(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/files/")
+	for _, f := range s.files {
+		if f.ID == id {
+			w.Header().Set("Content-Type", f.Mimetype)
+			w.Write(f.content)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func failWith(code string) handler {
+	return func(*Server, url.Values) (response, error) {
+		return nil, apiError(code)
+	}
+}
+
+func (s *Server) emptyItems(url.Values) (response, error) {
+	return response{"items": []interface{}{}, "paging": slack.Paging{Page: 1, Pages: 1}}, nil
+}
+
+func (s *Server) user(id string) (slack.User, bool) {
+	for _, u := range s.users {
+		if u.ID == id || u.Name == id {
+			return u, true
+		}
+	}
+	return slack.User{}, false
+}
+
+func (s *Server) conversation(id string) (*conversation, error) {
+	for _, c := range s.conversations {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, apiError("channel_not_found")
+}
+
+func (s *Server) isMember(c *conversation) bool {
+	for _, m := range c.Members {
+		if m == s.self {
+			return true
+		}
+	}
+	return false
+}
+
+// timestamp returns a Slack timestamp for t that is not yet used in c.
+func timestamp(c *conversation, t time.Time) string {
+	for us := t.UnixNano() / 1000; ; us++ {
+		ts := fmt.Sprintf("%d.%06d", us/1e6, us%1e6)
+		if c.message(ts) == nil {
+			return ts
+		}
+	}
+}
+
+func (c *conversation) message(ts string) *slack.Message {
+	for _, m := range c.messages {
+		if m.Timestamp == ts {
+			return m
+		}
+	}
+	return nil
+}
+
+func parseTimestamp(ts string) float64 {
+	f, _ := strconv.ParseFloat(ts, 64)
+	return f
+}
+
+func intParam(form url.Values, name string, def int) int {
+	n, err := strconv.Atoi(form.Get(name))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// cursorPage returns the bounds of the page starting at the offset encoded in cursor, and the cursor of the next page.
+func cursorPage(form url.Values, n, defaultLimit int) (from, to int, next string) {
+	from, _ = strconv.Atoi(form.Get("cursor"))
+	if from > n {
+		from = n
+	}
+	to = from + intParam(form, "limit", defaultLimit)
+	if to >= n {
+		return from, n, ""
+	}
+	return from, to, strconv.Itoa(to)
+}
@@ -0,0 +1,24 @@
+package fakeslack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSyntheticIsStable(t *testing.T) {
+	a, b := Synthetic(), Synthetic()
+	defer a.Close()
+	defer b.Close()
+	for _, c := range []string{"C0GENERAL", "G0SECRET", "D00ALICE"} {
+		if !reflect.DeepEqual(a.Messages(c), b.Messages(c)) {
+			t.Errorf("%s: the messages of two synthetic workspaces differ", c)
+		}
+	}
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	for _, m := range a.Messages("C0GENERAL") {
+		if posted := parseTimestamp(m.Timestamp); posted >= float64(day.Unix()) {
+			t.Errorf("message %s was posted today", m.Timestamp)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateFormat is the format of -after and -before dates.
+const dateFormat = "2006-01-02"
+
+// parseDate accepts a date (2006-01-02, local time) or an RFC3339 timestamp.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation(dateFormat, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseAge accepts a positive Go duration, or a positive number of days ("90d") or weeks ("12w").
+func parseAge(s string) (time.Duration, error) {
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q (expected e.g. 90d, 12w or 36h)", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid age %q (must be positive)", s)
+	}
+	return d, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n := strings.TrimSuffix(s, suffix); n != s {
+			if v, err := strconv.Atoi(n); err == nil {
+				return time.Duration(v) * unit, nil
+			}
+		}
+	}
+	return time.ParseDuration(s)
+}
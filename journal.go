@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+const (
+	itemPending = "pending"
+	itemDone    = "done"
+	itemFailed  = "failed"
+)
+
+// A journal is an append-only JSON-lines log of the state of every wipe item.
+// The first line is the plan header (without items); each further line records a state change of one item.
+type journal struct {
+	mu     sync.Mutex
+	file   *jsonlFile
+	header plan
+	items  []wipe.Item
+	states map[string]journalRecord
+}
+
+type journalRecord struct {
+	Item  wipe.Item
+	State string
+	Error string `json:",omitempty"`
+}
+
+func createJournal(path string, header plan) (*journal, error) {
+	file, err := createJSONL(path)
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{
+		file:   file,
+		header: header,
+		states: make(map[string]journalRecord),
+	}
+	j.header.Items = nil
+	if err := file.append(j.header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return j, file.Sync()
+}
+
+func readJournal(path string) (*journal, error) {
+	j := &journal{states: make(map[string]journalRecord)}
+	err := readJSONL(path, &j.header, func(line []byte) error {
+		var r journalRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		j.record(r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if j.header.Version != planVersion {
+		return nil, fmt.Errorf("unsupported journal version %d (want %d)", j.header.Version, planVersion)
+	}
+	return j, nil
+}
+
+// openJournal reads the journal at path and re-opens it for appending.
+func openJournal(path string) (*journal, error) {
+	j, err := readJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if j.file, err = appendJSONL(path); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *journal) record(r journalRecord) {
+	key := r.Item.Key()
+	if _, ok := j.states[key]; !ok {
+		j.items = append(j.items, r.Item)
+	}
+	j.states[key] = r
+}
+
+func (j *journal) write(r journalRecord) error {
+	j.record(r)
+	return j.file.append(r)
+}
+
+// add records items as pending, unless they are already known.
+func (j *journal) add(items []wipe.Item) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, item := range items {
+		if _, ok := j.states[item.Key()]; ok {
+			continue
+		}
+		if err := j.write(journalRecord{Item: item, State: itemPending}); err != nil {
+			return err
+		}
+	}
+	return j.file.Sync()
+}
+
+// mark records the outcome of an item.
+func (j *journal) mark(item wipe.Item, err error) error {
+	if j == nil {
+		return nil
+	}
+	r := journalRecord{Item: item, State: itemDone}
+	if err != nil {
+		r.State = itemFailed
+		r.Error = err.Error()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(r)
+}
+
+// unfinished returns the items that are pending or failed, in the order they were added.
+func (j *journal) unfinished() []wipe.Item {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var items []wipe.Item
+	for _, item := range j.items {
+		if j.states[item.Key()].State != itemDone {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (j *journal) counts() map[string]int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	counts := make(map[string]int)
+	for _, r := range j.states {
+		counts[r.State]++
+	}
+	return counts
+}
+
+func (j *journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// startJournal creates a fresh journal for this run, refusing to overwrite one with items that were not attempted yet.
+// Failed items do not block a new run: they are also in the failed file, for -retry-failed.
+func startJournal(header plan) {
+	if config.Journal == "" {
+		return
+	}
+	if j, err := readJournal(config.Journal); err == nil {
+		if n := j.counts()[itemPending]; n > 0 {
+			fatalf("journal %q has %d items that were not attempted; continue them with -resume or remove the journal", config.Journal, n)
+		}
+	}
+	j, err := createJournal(config.Journal, header)
+	if err != nil {
+		fatalf("create journal %q: %v", config.Journal, err)
+	}
+	state.Journal = j
+}
+
+func resumeJournal(path string) {
+	j, err := openJournal(path)
+	if err != nil {
+		fatalf("open journal %q: %v", path, err)
+	}
+	defer j.Close()
+	counts := j.counts()
+	log.Printf("resuming journal %q: %d done, %d pending, %d failed", path, counts[itemDone], counts[itemPending], counts[itemFailed])
+	if err := verifyPlan(j.header); err != nil {
+		fatalf("verify journal %q: %v", path, err)
+	}
+	if j.header.Streamed {
+		log.Printf("journal %q is from a -stream run: messages it did not fetch before it stopped are not in it (run the -stream command again to find them)", path)
+	}
+	items := j.unfinished()
+	if len(items) == 0 {
+		log.Printf("nothing left to do")
+		return
+	}
+	state.Journal = j
+	wipeItems(state.Context, j.header, items, fmt.Sprintf("left over in journal %q", path))
+}
+
+func journalAdd(items []wipe.Item) {
+	if err := state.Journal.add(items); err != nil {
+		log.Printf("journal: %v", err)
+	}
+}
+
+func journalMark(item wipe.Item, err error) {
+	if err := state.Journal.mark(item, err); err != nil {
+		log.Printf("journal: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+func messageItem(ts string) wipe.Item {
+	return wipe.Item{Kind: wipe.KindMessage, Action: wipe.ActionDelete, Channel: "C00GENERAL", Timestamp: ts}
+}
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	items := []wipe.Item{messageItem("1.000001"), messageItem("1.000002"), messageItem("1.000003")}
+	j, err := createJournal(path, plan{Version: planVersion, UserID: testUser, Items: items})
+	if err != nil {
+		t.Fatalf("createJournal: %v", err)
+	}
+	if err := j.add(items); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	j.mark(items[0], nil)
+	j.mark(items[1], errors.New("cant_delete_message"))
+	j.Close()
+
+	j, err = openJournal(path)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	if j.header.UserID != testUser || j.header.Items != nil {
+		t.Errorf("header = %+v, want the plan without its items", j.header)
+	}
+	want := map[string]int{itemDone: 1, itemFailed: 1, itemPending: 1}
+	if counts := j.counts(); !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+	if got := j.unfinished(); !reflect.DeepEqual(got, items[1:]) {
+		t.Errorf("unfinished = %v, want the failed and the pending item, in order", got)
+	}
+	j.add(items)
+	j.mark(items[2], nil)
+	j.Close()
+
+	j, err = readJournal(path)
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if got := j.unfinished(); !reflect.DeepEqual(got, items[1:2]) {
+		t.Errorf("unfinished = %v, want only the failed item", got)
+	}
+}
+
+// writeTestJournal writes a journal for the messages in the test workspace's channel, with the given states.
+func writeTestJournal(t *testing.T, path string, messages []string, states ...string) {
+	t.Helper()
+	header := plan{Version: planVersion, User: "me", UserID: testUser, Channels: []planChannel{{ID: "C00GENERAL", Name: "general"}}}
+	j, err := createJournal(path, header)
+	if err != nil {
+		t.Fatalf("createJournal: %v", err)
+	}
+	defer j.Close()
+	for i, ts := range messages {
+		if err := j.write(journalRecord{Item: messageItem(ts), State: states[i]}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func TestResume(t *testing.T) {
+	s := newWorkspace(t)
+	dir := t.TempDir()
+	var messages []string
+	for _, m := range s.Messages("C00GENERAL") {
+		messages = append(messages, m.Timestamp)
+	}
+	writeTestJournal(t, filepath.Join(dir, "journal"), messages, itemDone, itemPending)
+	if code := runCLI(t, cliArgs(dir, "-resume")...); code != exitOK {
+		t.Fatalf("exit code %d, want %d", code, exitOK)
+	}
+	if left := s.Messages("C00GENERAL"); len(left) != 1 || left[0].Timestamp != messages[0] {
+		t.Errorf("messages left = %v, want only the one journaled as done", left)
+	}
+	j, err := readJournal(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatalf("readJournal: %v", err)
+	}
+	if n := len(j.unfinished()); n != 0 {
+		t.Errorf("%d unfinished items after -resume, want 0", n)
+	}
+}
+
+func TestNewRunRefusesPendingJournal(t *testing.T) {
+	s := newWorkspace(t)
+	dir := t.TempDir()
+	var messages []string
+	for _, m := range s.Messages("C00GENERAL") {
+		messages = append(messages, m.Timestamp)
+	}
+	writeTestJournal(t, filepath.Join(dir, "journal"), messages, itemFailed, itemPending)
+	if code := runCLI(t, cliArgs(dir, "-channel=general", "-messages")...); code != exitFatal {
+		t.Errorf("exit code %d, want %d", code, exitFatal)
+	}
+	if n := len(s.Messages("C00GENERAL")); n != 2 {
+		t.Errorf("%d messages left, want 2 (nothing wiped)", n)
+	}
+}
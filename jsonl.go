@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A jsonlFile is an append-only JSON-lines file, as used by the journal and the watch store.
+type jsonlFile struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// createJSONL creates (or truncates) the file at path.
+func createJSONL(path string) (*jsonlFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlFile{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// appendJSONL opens the file at path for appending. A torn last line is cut off first,
+// so that the lines appended after it can be read back.
+func appendJSONL(path string) (*jsonlFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	size, err := intactSize(f)
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &jsonlFile{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// intactSize returns the length of the lines of f up to the first one that is incomplete or not valid JSON.
+func intactSize(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+	var size int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF || (err == nil && !json.Valid(line)) {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(line))
+	}
+}
+
+// append writes v as a line. Lines are only durable once Sync returns.
+func (l *jsonlFile) append(v interface{}) error {
+	return l.enc.Encode(v)
+}
+
+func (l *jsonlFile) Sync() error {
+	return l.f.Sync()
+}
+
+func (l *jsonlFile) Close() error {
+	if err := l.f.Sync(); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
+
+// readJSONL reads the JSON-lines file at path: the first line into header (unless header is nil),
+// and then each further line with record. A last line that record cannot parse is a torn write from an
+// interrupted run, and is skipped (appendJSONL cuts it off); any other line that cannot be parsed is an error.
+func readJSONL(path string, header interface{}, record func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	if header != nil {
+		line++
+		if !s.Scan() {
+			if err := s.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("empty file")
+		}
+		if err := json.Unmarshal(s.Bytes(), header); err != nil {
+			return fmt.Errorf("parse header: %v", err)
+		}
+	}
+	var torn error
+	for s.Scan() {
+		line++
+		if torn != nil {
+			return torn
+		}
+		if err := record(s.Bytes()); err != nil {
+			torn = fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	return s.Err()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testLine struct{ N int }
+
+// readTestLines reads the file written by writeTestFile, and returns the header and record numbers.
+func readTestLines(path string) (int, []int, error) {
+	var header testLine
+	var records []int
+	err := readJSONL(path, &header, func(line []byte) error {
+		var r testLine
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		records = append(records, r.N)
+		return nil
+	})
+	return header.N, records, err
+}
+
+func writeTestFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.jsonl")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadJSONL(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []int
+		err     string
+	}{
+		{"intact", "{\"N\":0}\n{\"N\":1}\n{\"N\":2}\n", []int{1, 2}, ""},
+		{"header only", "{\"N\":0}\n", nil, ""},
+		{"torn tail", "{\"N\":0}\n{\"N\":1}\n{\"N\":", []int{1}, ""},
+		{"bad last line", "{\"N\":0}\n{\"N\":1}\nxyz\n", []int{1}, ""},
+		{"bad middle line", "{\"N\":0}\n{\"N\":1}\nxyz\n{\"N\":3}\n", nil, "line 3"},
+		{"empty", "", nil, "empty file"},
+		{"bad header", "xyz\n{\"N\":1}\n", nil, "parse header"},
+	}
+	for _, test := range tests {
+		_, records, err := readTestLines(writeTestFile(t, test.content))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: err = %v, want one containing %q", test.name, err, test.err)
+		case test.err == "" && !reflect.DeepEqual(records, test.want):
+			t.Errorf("%s: records = %v, want %v", test.name, records, test.want)
+		}
+	}
+}
+
+func TestAppendJSONLAfterTornLine(t *testing.T) {
+	for _, tail := range []string{"{\"N\":", "xyz\n", ""} {
+		path := writeTestFile(t, "{\"N\":0}\n{\"N\":1}\n"+tail)
+		f, err := appendJSONL(path)
+		if err != nil {
+			t.Fatalf("appendJSONL: %v", err)
+		}
+		if err := f.append(testLine{2}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		header, records, err := readTestLines(path)
+		if err != nil || header != 0 || !reflect.DeepEqual(records, []int{1, 2}) {
+			t.Errorf("tail %q: read back %d, %v, %v, want 0, [1 2], no error", tail, header, records, err)
+		}
+	}
+}
+
+func TestCreateJSONL(t *testing.T) {
+	path := writeTestFile(t, "old content\n")
+	f, err := createJSONL(path)
+	if err != nil {
+		t.Fatalf("createJSONL: %v", err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := f.append(testLine{n}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	f.Close()
+	if _, records, err := readTestLines(path); err != nil || !reflect.DeepEqual(records, []int{1}) {
+		t.Errorf("read back %v, %v, want [1], no error", records, err)
+	}
+	if _, err := appendJSONL(filepath.Join(filepath.Dir(path), "missing")); !os.IsNotExist(err) {
+		t.Errorf("appendJSONL(missing): err = %v, want a not-exist error", err)
+	}
+}
@@ -2,490 +2,633 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strings"
-	"sync"
 	"time"
-	"unicode"
-
-	"golang.org/x/text/runes"
 
 	"github.com/nlopes/slack"
 	"github.com/schollz/progressbar"
+	"github.com/sgreben/slack-wipe/fakeslack"
+	"github.com/sgreben/slack-wipe/wipe"
 )
 
 var config struct {
-	Channel      string
-	Token        string
-	WipeMessages bool
-	WipeFiles    bool
-	Path         string `json:"-"`
-	AutoApprove  bool
-	Redact       bool
-	RedactMarker rune
-	IM           string
+	Channel       string
+	Token         string
+	WipeMessages  bool
+	WipeFiles     bool
+	WipeReactions bool
+	WipePins      bool
+	WipeStars     bool
+	Path          string `json:"-"`
+	AutoApprove   bool
+	Redact        bool
+	Overwrite     bool
+	RedactMarker  rune
+	RedactStyle   string
+	RedactText    string
+	RedactMarkup  string
+	KeepUnfurls   bool
+	IM            string
+	AllChannels   bool
+	AllIMs        bool
+	KeepIMs       string
+	Plan          string `json:"-"`
+	Apply         string `json:"-"`
+	Journal       string
+	Resume        bool `json:"-"`
+	FailedFile    string
+	RetryFailed   string `json:"-"`
+	Concurrency   int
+	Stream        bool
+	Daemon        string `json:"-"`
+	Watch         bool
+	TTL           string
+	WatchStore    string
+	Sandbox       bool `json:"-"`
+	Export        string
+	Download      string
+	After         string
+	Before        string
+	OlderThan     string
+	Match         string
+	Exclude       string
+	LogFormat     string `json:"-"`
+	Report        string `json:"-"`
 }
 
 var state struct {
-	API          *slack.Client
-	RTM          *slack.RTM
-	Channel      slack.Channel
-	User         string
-	UserID       string
-	MemberList   []string
-	MemberIDMap  map[string]bool
-	UserMessages []slack.SearchMessage
-	UserFiles    []slack.File
-	Users        map[string]slack.User
+	Context         context.Context
+	Wiper           *wipe.Wiper
+	RTM             *slack.RTM
+	Channel         slack.Channel
+	Channels        []slack.Channel
+	ChannelNames    map[string]string
+	UserMessages    []slack.SearchMessage
+	UserFiles       []slack.File
+	Plan            plan
+	Journal         *journal
+	After           time.Time
+	Before          time.Time
+	Match           *regexp.Regexp
+	Exclude         *regexp.Regexp
+	Redactor        wipe.Redactor
+	ChannelPatterns []string
+	Results         *resultCollector
+	FetchBar        *progressbar.ProgressBar
+	Progress        progress
+	Streaming       bool
+	TTLs            []ttlRule
+	Policies        *policyFile
+	WatchStore      *watchStore
+	Started         time.Time
+	RateLimits      rateLimitTally
 }
 
-var rateLimitTier4 = time.Tick(time.Minute / 100)
-var rateLimitTier3 = time.Tick(time.Minute / 50)
-var rateLimitTier2 = time.Tick(time.Minute / 20)
-
 func init() {
 	config.RedactMarker = '█'
 	log.SetOutput(os.Stderr)
 	log.SetFlags(log.Ldate | log.Ltime)
-	flag.StringVar(&config.Channel, "channel", "", "channel name (without '#')")
+	flag.StringVar(&config.Channel, "channel", "", "comma-separated list of channel names or glob patterns (without '#')")
+	flag.BoolVar(&config.AllChannels, "all-channels", false, "wipe all public and private channels you are a member of")
 	flag.StringVar(&config.IM, "im", "", "comma-separated list of usernames")
+	flag.BoolVar(&config.AllIMs, "all-ims", false, "wipe all IMs and group IMs (including archived ones)")
+	flag.StringVar(&config.KeepIMs, "keep-ims", "", "with -all-ims: comma-separated list of usernames whose IMs and group IMs are kept")
 	flag.StringVar(&config.Token, "token", "", "API token")
 	flag.StringVar(&config.Path, "config", "slack-wipe.json", "")
+	flag.BoolVar(&config.Sandbox, "sandbox", false, "rehearse against a fake workspace with synthetic data (nothing real is wiped)")
 	flag.BoolVar(&config.WipeMessages, "messages", false, "wipe messages")
 	flag.BoolVar(&config.WipeFiles, "files", false, "wipe files")
+	flag.BoolVar(&config.WipeReactions, "reactions", false, "remove your reactions")
+	flag.BoolVar(&config.WipePins, "pins", false, "unpin your pinned messages and files")
+	flag.BoolVar(&config.WipeStars, "stars", false, "remove your stars (saved items)")
 	flag.BoolVar(&config.AutoApprove, "auto-approve", false, "do not ask for confirmation")
+	flag.IntVar(&config.Concurrency, "concurrency", 4, "number of concurrent delete/redact requests")
+	flag.BoolVar(&config.Stream, "stream", false, "fetch and wipe messages page by page, without listing them all first (for very large histories)")
+	flag.StringVar(&config.Daemon, "daemon", "", "keep running, and apply the retention policies in this file on a schedule")
+	flag.BoolVar(&config.Watch, "watch", false, "keep running, and wipe each new message you post once its time-to-live (-ttl) has passed")
+	flag.StringVar(&config.TTL, "ttl", "", "with -watch: time-to-live of your messages (e.g. 24h, 7d), or a comma-separated list of CONVERSATION=AGE rules (glob patterns, @username for IMs; the first match applies)")
+	flag.StringVar(&config.WatchStore, "watch-store", "slack-wipe.watch", "with -watch: keep the pending messages in this file")
 	flag.BoolVar(&config.Redact, "redact", false, "redact messages (instead of delete)")
-	flag.Parse()
+	flag.BoolVar(&config.Overwrite, "overwrite", false, "overwrite messages with redacted text, and delete them once the edit is confirmed")
+	flag.StringVar(&config.RedactStyle, "redact-style", wipe.StyleShape, "redaction style: "+strings.Join(wipe.RedactStyles, ", "))
+	flag.StringVar(&config.RedactText, "redact-text", "[redacted]", "replacement text for -redact-style=fixed")
+	flag.StringVar(&config.RedactMarkup, "redact-markup", "", "what to do with each kind of Slack markup when redacting, e.g. user=keep,url=remove (types: text, user, channel, url, emoji, code, codeblock; treatments: keep, mask, remove; default mask)")
+	flag.BoolVar(&config.KeepUnfurls, "keep-unfurls", false, "with -redact: keep link previews (with redacted text) instead of removing them")
+	flag.StringVar(&config.Plan, "plan", "", "write a wipe plan to this file (instead of wiping)")
+	flag.StringVar(&config.Apply, "apply", "", "execute the wipe plan in this file")
+	flag.StringVar(&config.Journal, "journal", "slack-wipe.journal", "record the progress of each item in this file (empty to disable)")
+	flag.BoolVar(&config.Resume, "resume", false, "continue the unfinished items in the journal")
+	flag.StringVar(&config.FailedFile, "failed-file", "slack-wipe.failed.json", "write items that could not be wiped to this file (empty to disable)")
+	flag.StringVar(&config.RetryFailed, "retry-failed", "", "retry the failed items written to this file by an earlier run")
+	flag.StringVar(&config.Export, "export", "", "export messages to a JSON-lines file in this directory before wiping them")
+	flag.StringVar(&config.Download, "download", "", "download files to this directory before wiping them (only verified downloads are wiped)")
+	flag.StringVar(&config.After, "after", "", "only wipe items posted at or after this date (2006-01-02 or RFC3339)")
+	flag.StringVar(&config.Before, "before", "", "only wipe items posted before this date (2006-01-02 or RFC3339)")
+	flag.StringVar(&config.OlderThan, "older-than", "", "only wipe items older than this age (e.g. 90d, 12w, 36h)")
+	flag.StringVar(&config.Match, "match", "", "only wipe messages whose text or attachment text matches this regular expression")
+	flag.StringVar(&config.Exclude, "exclude", "", "do not wipe messages whose text or attachment text matches this regular expression")
+	flag.StringVar(&config.LogFormat, "log-format", "text", "log format: text, or json for one JSON event per line on stderr (and nothing on stdout; requires -auto-approve)")
+	flag.StringVar(&config.Report, "report", "", "write a summary of the run to this file when it ends (CSV if the name ends in .csv, JSON otherwise)")
+}
+
+// configure parses the command line args and the config file, and checks them.
+func configure(args []string) {
+	state.Started = time.Now().UTC()
+	flag.CommandLine.Parse(args)
+	if err := setLogFormat(config.LogFormat); err != nil {
+		config.LogFormat = "text"
+		configErrorf("-log-format: %v", err)
+	}
 
 	f, err := os.Open(config.Path)
 	if err == nil {
 		defer f.Close()
 		if err := json.NewDecoder(f).Decode(&config); err != nil {
-			log.Fatalf("parse config file %q: %v", config.Path, err)
+			configErrorf("parse config file %q: %v", config.Path, err)
 		}
 	}
 
-	if config.Channel == "" && config.IM == "" {
-		log.Fatalf("-channel or -im is required")
+	if config.Concurrency < 1 {
+		configErrorf("-concurrency must be at least 1")
 	}
-	state.MemberList = strings.Split(config.IM, ",")
-	if config.Token == "" {
-		log.Fatalf("-token is required")
+	if config.RetryFailed != "" {
+		if config.Apply != "" {
+			configErrorf("-apply and -retry-failed are mutually exclusive")
+		}
+		config.Apply = config.RetryFailed
 	}
-}
-
-func main() {
-	state.API = slack.New(config.Token)
-	state.RTM = state.API.NewRTM()
-	go state.RTM.ManageConnection()
-	log.Printf("looking up user for token %s...%s", config.Token[:8], config.Token[len(config.Token)-9:])
-	if err := fetchUserInfo(); err != nil {
-		log.Fatalf("fetch user info: %v", err)
+	if config.Plan != "" && config.Apply != "" {
+		configErrorf("-plan and -apply are mutually exclusive")
 	}
-	log.Printf("user: @%s (@%s)", state.User, state.UserID)
-	switch {
-	case config.IM != "":
-		log.Print("fetching users")
-		if err := fetchUsers(); err != nil {
-			log.Fatalf("fetch users: %v", err)
+	if config.Resume && (config.Plan != "" || config.Apply != "") {
+		configErrorf("-resume cannot be combined with -plan or -apply")
+	}
+	if (config.Export != "" || config.Download != "") && (config.Apply != "" || config.Resume) {
+		configErrorf("-export and -download cannot be combined with -apply or -resume")
+	}
+	if config.Stream && (config.Plan != "" || config.Apply != "" || config.Resume || config.Export != "") {
+		configErrorf("-stream cannot be combined with -plan, -apply, -resume or -export")
+	}
+	if config.Watch {
+		if config.Plan != "" || config.Apply != "" || config.Resume || config.Stream || config.Export != "" || config.Download != "" {
+			configErrorf("-watch cannot be combined with -plan, -apply, -resume, -stream, -export or -download")
 		}
-		state.MemberIDMap = make(map[string]bool, len(state.MemberList))
-		state.MemberIDMap[state.UserID] = true
-		for _, m := range state.MemberList {
-			m = strings.TrimSpace(m)
-			m = strings.TrimPrefix(m, "@")
-			state.MemberIDMap[state.Users[m].ID] = true
+		if !config.WipeMessages || config.WipeFiles || config.WipeReactions || config.WipePins || config.WipeStars {
+			configErrorf("-watch only wipes messages (use -messages without -files, -reactions, -pins or -stars)")
 		}
-		log.Printf("looking up channel ID for IM with %v", state.MemberList)
-		if err := channelForIM(); err != nil {
-			log.Fatalf("fetch channel info for conversation %q: %v", config.IM, err)
+		if config.Sandbox {
+			configErrorf("-watch needs Slack's real-time API, which the sandbox does not have")
 		}
-	default:
-		log.Printf("looking up channel ID for %q", config.Channel)
-		if err := channelForChannelName(config.Channel); err != nil {
-			log.Fatalf("fetch channel info for channel %q: %v", config.Channel, err)
+		rules, err := parseTTLs(config.TTL)
+		if err != nil {
+			configErrorf("-ttl: %v", err)
 		}
+		state.TTLs = rules
+	} else if config.TTL != "" {
+		configErrorf("-ttl requires -watch")
+	}
+	if config.Daemon != "" {
+		if config.Channel != "" || config.AllChannels || config.IM != "" || config.AllIMs ||
+			config.WipeMessages || config.WipeFiles || config.WipeReactions || config.WipePins || config.WipeStars ||
+			config.After != "" || config.Before != "" || config.OlderThan != "" {
+			configErrorf("-daemon takes the conversations, what to wipe and the maximum age from the policy file")
+		}
+		if config.Plan != "" || config.Apply != "" || config.Resume || config.Stream || config.Watch || config.Export != "" || config.Download != "" {
+			configErrorf("-daemon cannot be combined with -plan, -apply, -resume, -stream, -watch, -export or -download")
+		}
+		policies, err := readPolicyFile(config.Daemon)
+		if err != nil {
+			configErrorf("-daemon: policy file %q: %v", config.Daemon, err)
+		}
+		state.Policies = policies
 	}
-	log.Printf("channel: %s (%s)", state.Channel.Name, state.Channel.ID)
-	if config.WipeMessages {
-		fetchAndWipeMessages()
+	if config.LogFormat == "json" && !config.AutoApprove && !config.Watch && config.Daemon == "" && config.Plan == "" {
+		configErrorf("-log-format=json requires -auto-approve (the approval prompt would not be a JSON event)")
 	}
-	if config.WipeFiles {
-		fetchAndWipeFiles()
+	if config.Resume && config.Journal == "" {
+		configErrorf("-resume requires -journal")
 	}
-}
-
-func fetchAndWipeMessages() {
-	verb := "delete"
-	if config.Redact {
-		verb = "redact"
+	if config.Channel == "" && config.IM == "" && !config.AllChannels && !config.AllIMs && config.Apply == "" && !config.Resume && config.Daemon == "" {
+		configErrorf("-channel, -all-channels, -im or -all-ims is required")
 	}
-	switch {
-	case state.Channel.IsMpIM || state.Channel.IsIM:
-		if err := fetchDirectMessages(); err != nil {
-			log.Fatalf("fetch messages for conversation %q: %v", state.Channel.Name, err)
+	targets := 0
+	for _, set := range []bool{config.Channel != "" || config.AllChannels, config.IM != "", config.AllIMs} {
+		if set {
+			targets++
 		}
-	default:
-		if err := fetchMessages(); err != nil {
-			log.Fatalf("fetch messages for channel %q: %v", state.Channel.Name, err)
+	}
+	if targets > 1 {
+		configErrorf("-channel/-all-channels, -im and -all-ims are mutually exclusive")
+	}
+	if config.KeepIMs != "" && !config.AllIMs {
+		configErrorf("-keep-ims requires -all-ims")
+	}
+	for _, p := range strings.Split(config.Channel, ",") {
+		p = strings.TrimPrefix(strings.TrimSpace(p), "#")
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			configErrorf("-channel: invalid pattern %q: %v", p, err)
 		}
+		state.ChannelPatterns = append(state.ChannelPatterns, p)
 	}
-	if !config.AutoApprove {
-		if !approvalPrompt(fmt.Sprintf("%s all %d messages?", verb, len(state.UserMessages))) {
-			log.Fatalf("aborted")
+	state.ChannelNames = make(map[string]string)
+	state.Results = newResultCollector()
+	if config.After != "" {
+		t, err := parseDate(config.After)
+		if err != nil {
+			configErrorf("-after: %v", err)
 		}
+		state.After = t
 	}
-	if config.Redact {
-		if err := redactAllUserMessages(); err != nil {
-			log.Fatalf("redact messages: %v", err)
+	if config.Before != "" {
+		t, err := parseDate(config.Before)
+		if err != nil {
+			configErrorf("-before: %v", err)
 		}
-		return
+		state.Before = t
 	}
-	if err := deleteAllUserMessages(); err != nil {
-		log.Fatalf("delete messages: %v", err)
+	if config.OlderThan != "" {
+		age, err := parseAge(config.OlderThan)
+		if err != nil {
+			configErrorf("-older-than: %v", err)
+		}
+		if t := time.Now().Add(-age); state.Before.IsZero() || t.Before(state.Before) {
+			state.Before = t
+		}
 	}
-}
-
-func fetchAndWipeFiles() {
-	if err := fetchFiles(); err != nil {
-		log.Fatalf("fetch files for channel %q: %v", state.Channel.Name, err)
+	if !state.After.IsZero() && !state.Before.IsZero() && !state.After.Before(state.Before) {
+		configErrorf("empty date range: %s to %s", state.After.Format(time.RFC3339), state.Before.Format(time.RFC3339))
 	}
-	if !config.AutoApprove {
-		if !approvalPrompt(fmt.Sprintf("wipe all %d files?", len(state.UserFiles))) {
-			log.Fatalf("aborted")
+	if config.Match != "" {
+		re, err := regexp.Compile(config.Match)
+		if err != nil {
+			configErrorf("-match: %v", err)
 		}
+		state.Match = re
 	}
-	if err := deleteAllUserFiles(); err != nil {
-		log.Fatalf("wipe files: %v", err)
+	if config.Exclude != "" {
+		re, err := regexp.Compile(config.Exclude)
+		if err != nil {
+			configErrorf("-exclude: %v", err)
+		}
+		state.Exclude = re
 	}
-}
-
-func approvalPrompt(prompt string) bool {
-	r := bufio.NewReader(os.Stdin)
-	fmt.Printf(`%s (only the answer "yes" will be accepted): `, prompt)
-	answer, err := r.ReadString('\n')
-	if err != nil {
-		log.Fatal(err)
+	if config.Redact && config.Overwrite {
+		configErrorf("-redact and -overwrite are mutually exclusive")
 	}
-	answer = strings.ToLower(strings.TrimSpace(answer))
-	return answer == "yes"
-}
-
-func channelForIM() error {
-	var channels []slack.Channel
-	first := true
-	cursor := ""
-	for first || cursor != "" {
-		first = false
-		<-rateLimitTier2
-		moreChannels, nextCursor, err := state.RTM.GetConversations(&slack.GetConversationsParameters{
-			Cursor:          cursor,
-			Types:           []string{"mpim", "im"},
-			ExcludeArchived: "false",
-			Limit:           1000,
-		})
+	if config.KeepUnfurls && !config.Redact && !config.Overwrite {
+		configErrorf("-keep-unfurls requires -redact or -overwrite")
+	}
+	if config.RedactMarkup != "" && !config.Redact && !config.Overwrite {
+		configErrorf("-redact-markup requires -redact or -overwrite")
+	}
+	if config.Redact || config.Overwrite {
+		r, err := wipe.NewRedactor(config.RedactStyle, config.RedactMarker, config.RedactText)
 		if err != nil {
-			return err
-		}
-		channels = append(channels, moreChannels...)
-		cursor = nextCursor
-	}
-channels:
-	for _, c := range channels {
-		switch {
-		case c.IsIM && len(state.MemberIDMap) == 2 && state.MemberIDMap[c.User]:
-			state.Channel = c
-			state.Channel.Name = fmt.Sprintf("IM with %v", state.MemberList)
-			return nil
-		case c.IsMpIM && len(state.MemberIDMap) > 2:
-			members, err := usersInConversation(c.ID)
-			if err != nil {
-				return fmt.Errorf("fetch conversation members: %v", err)
-			}
-			if len(members) != len(state.MemberIDMap) {
-				continue
+			configErrorf("-redact-style: %v", err)
+		}
+		switch config.RedactStyle {
+		case wipe.StyleFixed, wipe.StylePlaceholder:
+			if config.RedactMarkup != "" {
+				configErrorf("-redact-markup cannot be combined with -redact-style=%s", config.RedactStyle)
 			}
-			for _, m := range members {
-				if !state.MemberIDMap[m] {
-					continue channels
-				}
+		default:
+			policy, err := wipe.ParseMarkupPolicy(config.RedactMarkup)
+			if err != nil {
+				configErrorf("-redact-markup: %v", err)
 			}
-			state.Channel = c
-			return nil
+			r = wipe.MarkupRedactor(r, policy)
 		}
+		state.Redactor = r
 	}
-	return fmt.Errorf("conversation not found: %q", config.IM)
+	if config.Sandbox {
+		config.Token = fakeslack.SandboxToken
+		config.Journal = sandboxPath(config.Journal)
+		config.FailedFile = sandboxPath(config.FailedFile)
+	}
+	if config.Token == "" {
+		configErrorf("-token is required")
+	}
+}
+
+func main() {
+	configure(os.Args[1:])
+	run()
+	exit(exitOK)
 }
 
-func usersInConversation(channelID string) ([]string, error) {
-	params := &slack.GetUsersInConversationParameters{
-		ChannelID: channelID,
+func run() {
+	state.Context = interruptContext()
+	if config.Sandbox {
+		sandbox := fakeslack.Synthetic()
+		defer sandbox.Close()
+		slack.SLACK_API = sandbox.APIURL()
+		log.Printf("sandbox: using a fake workspace at %s", sandbox.URL)
+	}
+	client := slack.New(config.Token)
+	if config.Watch {
+		state.RTM = client.NewRTM()
+		go state.RTM.ManageConnection()
 	}
-	var users []string
-	<-rateLimitTier4
-	moreUsers, nextCursor, err := state.RTM.GetUsersInConversation(params)
+	log.Printf("looking up user for token %s...%s", config.Token[:8], config.Token[len(config.Token)-9:])
+	w, err := wipe.New(state.Context, wiperOptions(client)...)
 	if err != nil {
-		return nil, err
+		listFailedf("fetch user info: %v", err)
+	}
+	state.Wiper = w
+	log.Printf("user: @%s (@%s)", w.User(), w.UserID())
+	if config.Resume {
+		resumeJournal(config.Journal)
+		return
+	}
+	if config.Apply != "" {
+		applyPlan(config.Apply)
+		return
 	}
-	users = append(users, moreUsers...)
-	for nextCursor != "" {
-		params.Cursor = nextCursor
-		<-rateLimitTier4
-		moreUsers, nextCursor, err = state.RTM.GetUsersInConversation(params)
+	if config.Daemon != "" {
+		daemon(state.Context, state.Policies)
+		return
+	}
+	ctx := state.Context
+	switch {
+	case config.IM != "":
+		log.Print("fetching users")
+		if _, err := w.Users(ctx); err != nil {
+			listFailedf("fetch users: %v", err)
+		}
+		var names, ids []string
+		for _, name := range strings.Split(config.IM, ",") {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+			u, ok := w.UserByName(name)
+			if !ok {
+				fatalf("-im: user not found: %q", name)
+			}
+			names = append(names, name)
+			ids = append(ids, u.ID)
+		}
+		log.Printf("looking up channel ID for IM with %v", names)
+		c, err := w.ResolveIM(ctx, ids)
 		if err != nil {
-			return nil, err
+			listFailedf("fetch channel info for conversation %q: %v", config.IM, err)
 		}
-		users = append(users, moreUsers...)
-	}
-	return users, nil
-}
-
-func channelForChannelName(channelName string) error {
-	var channels []slack.Channel
-	first := true
-	cursor := ""
-	for first || cursor != "" {
-		first = false
-		<-rateLimitTier2
-		moreChannels, nextCursor, err := state.RTM.GetConversations(&slack.GetConversationsParameters{
-			Cursor:          cursor,
-			Types:           []string{"private_channel", "public_channel"},
-			ExcludeArchived: "false",
-			Limit:           1000,
-		})
+		if c.IsIM {
+			c.Name = fmt.Sprintf("IM with %v", names)
+		}
+		state.Channels = []slack.Channel{c}
+	case config.AllIMs:
+		log.Print("fetching users")
+		if _, err := w.Users(ctx); err != nil {
+			listFailedf("fetch users: %v", err)
+		}
+		keep := make(map[string]bool)
+		for _, name := range strings.Split(config.KeepIMs, ",") {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+			if name == "" {
+				continue
+			}
+			u, ok := w.UserByName(name)
+			if !ok {
+				fatalf("-keep-ims: user not found: %q", name)
+			}
+			keep[u.ID] = true
+		}
+		log.Printf("looking up all IMs and group IMs (keeping %d people's)", len(keep))
+		channels, err := w.AllIMs(ctx, keep)
+		if err != nil {
+			listFailedf("fetch IMs: %v", err)
+		}
+		state.Channels = channels
+	default:
+		if config.AllChannels {
+			log.Print("looking up all channels you are a member of")
+		} else {
+			log.Printf("looking up channel IDs for %q", config.Channel)
+		}
+		channels, err := w.ResolveChannels(ctx, state.ChannelPatterns, config.AllChannels)
 		if err != nil {
-			return err
+			listFailedf("fetch channel info for channels %q: %v", config.Channel, err)
 		}
-		channels = append(channels, moreChannels...)
-		cursor = nextCursor
+		state.Channels = channels
 	}
-	for _, c := range channels {
-		switch {
-		case c.Name == channelName:
-			state.Channel = c
-			return nil
+	if config.Watch {
+		for _, c := range state.Channels {
+			resolved(c)
 		}
+		watch(ctx, state.Channels)
+		return
 	}
-	return fmt.Errorf("channel not found: %q", channelName)
-}
-
-func fetchUserInfo() error {
-	<-rateLimitTier3
-	identity, err := state.RTM.AuthTest()
-	if err != nil {
-		return err
+	var reacted []slack.ReactedItem
+	if config.WipeReactions {
+		if reacted, err = w.Reactions(ctx); err != nil {
+			listFailedf("fetch reactions: %v", err)
+		}
 	}
-	state.User = identity.User
-	state.UserID = identity.UserID
-	return nil
+	var starred []slack.Item
+	if config.WipeStars {
+		if starred, err = w.Stars(ctx); err != nil {
+			listFailedf("fetch stars: %v", err)
+		}
+	}
+	var items []wipe.Item
+	for _, c := range state.Channels {
+		state.Channel = c
+		resolved(c)
+		log.Printf("channel: %s (%s)", state.Channel.Name, state.Channel.ID)
+		if config.WipeMessages && !config.Stream {
+			items = append(items, fetchMessageItems()...)
+		}
+		if config.WipeFiles {
+			items = append(items, fetchFileItems()...)
+		}
+		if config.WipeReactions {
+			items = append(items, w.ReactionItems(c, reacted)...)
+		}
+		if config.WipePins {
+			pins, err := w.PinItems(ctx, c)
+			if err != nil {
+				listFailedf("fetch pins for channel %q: %v", state.Channel.Name, err)
+			}
+			items = append(items, pins...)
+		}
+		if config.WipeStars {
+			items = append(items, w.StarItems(c, starred)...)
+		}
+	}
+	items = dedupeFileItems(items)
+	if config.Plan != "" {
+		state.Plan = newPlan()
+		state.Plan.Items = items
+		if err := writePlan(config.Plan, state.Plan); err != nil {
+			fatalf("write plan %q: %v", config.Plan, err)
+		}
+		log.Printf("wrote plan for %d items to %q", len(state.Plan.Items), config.Plan)
+		return
+	}
+	header := newPlan()
+	header.Streamed = config.Stream
+	startJournal(header)
+	defer state.Journal.Close()
+	if config.Stream {
+		streamWipe(ctx, header, items)
+		return
+	}
+	wipeItems(ctx, header, items, "")
 }
 
-func fetchUsers() error {
-	users, err := state.RTM.GetUsers()
-	if err != nil {
-		return err
+// wiperOptions returns the options of state.Wiper, as given by the flags.
+func wiperOptions(client *slack.Client) []wipe.Option {
+	options := []wipe.Option{
+		wipe.WithClient(client),
+		wipe.WithTargets(wipe.Targets{
+			Messages:  config.WipeMessages,
+			Files:     config.WipeFiles,
+			Reactions: config.WipeReactions,
+			Pins:      config.WipePins,
+			Stars:     config.WipeStars,
+		}),
+		wipe.WithDateRange(state.After, state.Before),
+		wipe.WithMatch(state.Match),
+		wipe.WithExclude(state.Exclude),
+		wipe.WithConcurrency(config.Concurrency),
+		wipe.WithEvents(handleEvent),
 	}
-	state.Users = make(map[string]slack.User, len(users))
-	for _, u := range users {
-		state.Users[u.Profile.DisplayName] = u
+	switch {
+	case config.Redact:
+		options = append(options, wipe.WithRedact(state.Redactor), wipe.WithUnfurls(config.KeepUnfurls))
+	case config.Overwrite:
+		options = append(options, wipe.WithOverwrite(state.Redactor), wipe.WithUnfurls(config.KeepUnfurls))
 	}
-	return nil
+	return options
 }
 
-func fetchDirectMessages() error {
-	params := &slack.GetConversationHistoryParameters{
-		ChannelID: state.Channel.ID,
-	}
-	<-rateLimitTier2
-	hist, err := state.RTM.GetConversationHistory(params)
-	if err != nil {
-		return err
-	}
-	var userMessages []slack.SearchMessage
-	for {
-		for _, m := range hist.Messages {
-			if m.User == state.UserID {
-				userMessages = append(userMessages, slack.SearchMessage{
-					Type:        m.Type,
-					Channel:     slack.CtxChannel{ID: state.Channel.ID, Name: state.Channel.Name},
-					User:        m.User,
-					Username:    m.Username,
-					Timestamp:   m.Timestamp,
-					Text:        m.Text,
-					Attachments: m.Attachments,
-				})
-			}
+// handleEvent renders the progress events of state.Wiper.
+func handleEvent(e wipe.Event) {
+	switch e := e.(type) {
+	case wipe.PageFetched:
+		logEvent("page_fetched", map[string]interface{}{
+			"conversation": e.Conversation,
+			"kind":         e.Kind,
+			"fetched":      e.Fetched,
+			"pages":        e.Pages,
+		})
+		if e.Pages == 0 || state.Streaming || config.Daemon != "" {
+			return
 		}
-		nextCursor := hist.ResponseMetaData.NextCursor
-		if nextCursor == "" || !hist.HasMore {
-			break
+		if e.Fetched == 1 {
+			state.FetchBar = progressbar.NewOptions(e.Pages, progressbar.OptionSetDescription("fetching "+e.Kind+"s"), progressbar.OptionSetWriter(out))
 		}
-		params.Cursor = nextCursor
-		<-rateLimitTier2
-		hist, err = state.RTM.GetConversationHistory(params)
-		if err != nil {
-			return err
+		state.FetchBar.Add(1)
+		if e.Fetched == e.Pages {
+			state.FetchBar.Finish()
+			fmt.Fprintln(out)
+		}
+	case wipe.ItemWiped:
+		itemEvent(e.Item, e.Attempts, e.Err)
+		if state.WatchStore != nil {
+			watchFinished(e.Item, e.Attempts, e.Err)
+			return
+		}
+		recordResult(e.Item, e.Attempts, e.Err)
+		state.Progress.Add(1)
+	case wipe.RateLimited:
+		state.RateLimits.add(e.Method, e.RetryAfter)
+		if config.LogFormat == "json" {
+			logEvent("rate_limited", map[string]interface{}{"method": e.Method, "retry_after_seconds": e.RetryAfter.Seconds()})
+			return
 		}
+		log.Printf("%s: rate limited, pausing for %v", e.Method, e.RetryAfter)
+	case wipe.Notice:
+		log.Print(e.Message)
 	}
-	state.UserMessages = userMessages
-	return nil
 }
 
-func fetchMessages() error {
-	params := slack.NewSearchParameters()
-	params.Count = 100
-	query := fmt.Sprintf("in:#%s from:@%s", config.Channel, state.UserID)
-	<-rateLimitTier2
-	resp, err := state.RTM.SearchMessages(query, params)
+// sandboxPath keeps the files of sandbox runs apart from those of real runs.
+func sandboxPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "sandbox-"+filepath.Base(path))
+}
+
+func fetchMessageItems() []wipe.Item {
+	messages, err := state.Wiper.Messages(state.Context, state.Channel)
 	if err != nil {
-		return err
-	}
-	messages := resp.Matches
-	pageMax := resp.PageCount
-	params.Page++
-	bar := progressbar.NewOptions(pageMax, progressbar.OptionSetDescription("fetching messages"))
-	bar.Add(1)
-	for params.Page <= pageMax {
-		<-rateLimitTier2
-		resp, err := state.RTM.SearchMessages(query, params)
-		if err != nil {
-			return err
-		}
-		messages = append(messages, resp.Matches...)
-		pageMax = resp.PageCount
-		params.Page++
-		bar.Add(1)
+		listFailedf("fetch messages for %q: %v", state.Channel.Name, err)
 	}
-	bar.Finish()
-	fmt.Println()
-	var userMessages []slack.SearchMessage
-	for _, m := range messages {
-		if m.User == state.UserID {
-			userMessages = append(userMessages, m)
+	state.UserMessages = messages
+	if config.Export != "" {
+		if _, err := state.Wiper.Users(state.Context); err != nil {
+			listFailedf("fetch users: %v", err)
 		}
+		path, err := exportMessages(config.Export)
+		if err != nil {
+			fatalf("export messages: %v", err)
+		}
+		log.Printf("exported %d messages to %q", len(state.UserMessages), path)
 	}
-	state.UserMessages = userMessages
-	return nil
+	return state.Wiper.MessageItems(state.Channel, state.UserMessages)
 }
 
-func fetchFiles() error {
-	params := slack.NewGetFilesParameters()
-	params.Count = 200
-	params.User = state.UserID
-	params.Channel = state.Channel.ID
-	<-rateLimitTier3
-	files, paging, err := state.RTM.GetFiles(params)
+func fetchFileItems() []wipe.Item {
+	files, err := state.Wiper.Files(state.Context, state.Channel)
 	if err != nil {
-		return err
-	}
-	pageMax := 1
-	if paging != nil {
-		pageMax = paging.Pages
-	}
-	params.Page++
-	bar := progressbar.NewOptions(pageMax, progressbar.OptionSetDescription("fetching files"))
-	bar.Add(1)
-	for params.Page <= pageMax {
-		<-rateLimitTier3
-		filesPage, paging, err := state.RTM.GetFiles(params)
+		listFailedf("fetch files for %q: %v", state.Channel.Name, err)
+	}
+	state.UserFiles = files
+	if config.Download != "" {
+		verified, err := downloadFiles(config.Download)
 		if err != nil {
-			return err
+			listFailedf("download files: %v", err)
 		}
-		files = append(files, filesPage...)
-		if paging != nil {
-			pageMax = paging.Pages
+		var files []slack.File
+		for _, f := range state.UserFiles {
+			if verified[f.ID] {
+				files = append(files, f)
+			}
+		}
+		if skipped := len(state.UserFiles) - len(files); skipped > 0 {
+			log.Printf("skipping %d files whose download could not be verified", skipped)
 		}
-		params.Page++
-		bar.Add(1)
+		log.Printf("downloaded %d files to %q", len(files), config.Download)
+		state.UserFiles = files
 	}
-	bar.Finish()
-	fmt.Println()
-	state.UserFiles = files
-	return nil
+	return wipe.FileItems(state.Channel, state.UserFiles)
 }
 
-func deleteAllUserMessages() error {
-	var errors []error
-	bar := progressbar.NewOptions(len(state.UserMessages), progressbar.OptionSetDescription("wiping messages"))
-	bar.RenderBlank()
-	var wg sync.WaitGroup
-	wg.Add(len(state.UserMessages))
-	for _, m := range state.UserMessages {
-		timestamp := m.Timestamp
-		go func() {
-			defer wg.Done()
-			defer bar.Add(1)
-			<-rateLimitTier3
-			if _, _, err := state.RTM.DeleteMessage(state.Channel.ID, timestamp); err != nil {
-				errors = append(errors, err)
+// dedupeFileItems drops repeated file items, since a file shared to several channels is listed for each of them.
+func dedupeFileItems(items []wipe.Item) []wipe.Item {
+	seen := make(map[string]bool)
+	deduped := items[:0]
+	for _, item := range items {
+		if item.Kind == wipe.KindFile {
+			if seen[item.File] {
+				continue
 			}
-		}()
-	}
-	wg.Wait()
-	bar.Finish()
-	fmt.Println()
-	if len(errors) > 0 {
-		return fmt.Errorf("%d errors (e.g. %v)", len(errors), errors[0])
+			seen[item.File] = true
+		}
+		deduped = append(deduped, item)
 	}
-	return nil
-}
-
-func deleteAllUserFiles() error {
-	var errors []error
-	bar := progressbar.NewOptions(len(state.UserFiles), progressbar.OptionSetDescription("wiping files"))
-	bar.RenderBlank()
-	for _, f := range state.UserFiles {
-		bar.Add(1)
-		<-rateLimitTier3
-		if err := state.RTM.DeleteFile(f.ID); err != nil {
-			errors = append(errors, err)
-		}
-	}
-	bar.Finish()
-	fmt.Println()
-	if len(errors) > 0 {
-		return fmt.Errorf("%d errors (e.g. %v)", len(errors), errors[0])
-	}
-	return nil
+	return deduped
 }
 
-func redactAllUserMessages() error {
-	var errors []error
-	bar := progressbar.NewOptions(len(state.UserMessages), progressbar.OptionSetDescription("redact messages"))
-	bar.RenderBlank()
-	var wg sync.WaitGroup
-	wg.Add(len(state.UserMessages))
-	for _, m := range state.UserMessages {
-		timestamp := m.Timestamp
-		redacted := redact(m.Text)
-		go func() {
-			defer wg.Done()
-			defer bar.Add(1)
-			<-rateLimitTier3
-			if _, _, _, err := state.RTM.UpdateMessage(state.Channel.ID, timestamp, redacted); err != nil {
-				errors = append(errors, err)
-			}
-		}()
-	}
-	wg.Wait()
-	bar.Finish()
-	fmt.Println()
-	if len(errors) > 0 {
-		return fmt.Errorf("%d errors (e.g. %v)", len(errors), errors[0])
+func approvalPrompt(prompt string) bool {
+	r := bufio.NewReader(os.Stdin)
+	fmt.Printf(`%s (only the answer "yes" will be accepted): `, prompt)
+	answer, err := r.ReadString('\n')
+	if err != nil {
+		fatalf("%v", err)
 	}
-	return nil
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "yes" && state.Context.Err() == nil
 }
-
-var (
-	redactTransformer = runes.Map(func(r rune) rune {
-		if unicode.IsSpace(r) || unicode.IsPunct(r) {
-			return r
-		}
-		return config.RedactMarker
-	})
-	redact = redactTransformer.String
-)
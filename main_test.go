@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/fakeslack"
+)
+
+const (
+	testToken = "xoxp-test"
+	testUser  = "U00000TEST"
+)
+
+// configDefaults is the config with the flags' default values (set in TestMain, after init has registered the flags).
+var configDefaults = config
+
+func TestMain(m *testing.M) {
+	configDefaults = config
+	os.Exit(m.Run())
+}
+
+type exitCode int
+
+// runCLI runs the command line with the given args (from fresh config and state), and returns its exit code.
+func runCLI(t *testing.T, args ...string) (code int) {
+	t.Helper()
+	config = configDefaults
+	s := reflect.ValueOf(&state).Elem()
+	s.Set(reflect.Zero(s.Type()))
+	osExit = func(code int) { panic(exitCode(code)) }
+	defer func() {
+		osExit = os.Exit
+		if r := recover(); r != nil {
+			c, ok := r.(exitCode)
+			if !ok {
+				panic(r)
+			}
+			code = int(c)
+		}
+	}()
+	configure(args)
+	run()
+	exit(exitOK)
+	return exitOK
+}
+
+// newWorkspace starts a fake workspace with a channel and two messages by the user in it.
+func newWorkspace(t *testing.T) *fakeslack.Server {
+	s := fakeslack.New(testToken, testUser)
+	t.Cleanup(s.Close)
+	slack.SLACK_API = s.APIURL()
+	s.AddUser(testUser, "me")
+	s.AddChannel("C00GENERAL", "general", false, testUser)
+	posted := time.Now().Add(-time.Hour)
+	s.Post("C00GENERAL", testUser, "first secret", posted, "")
+	s.Post("C00GENERAL", testUser, "second secret", posted.Add(time.Second), "")
+	return s
+}
+
+// cliArgs returns the args common to all test runs, with the journal and the failed file in dir.
+func cliArgs(dir string, args ...string) []string {
+	return append([]string{
+		"-config", filepath.Join(dir, "slack-wipe.json"),
+		"-token", testToken,
+		"-auto-approve",
+		"-journal", filepath.Join(dir, "journal"),
+		"-failed-file", filepath.Join(dir, "failed.json"),
+	}, args...)
+}
+
+func TestRetryFailed(t *testing.T) {
+	s := newWorkspace(t)
+	dir := t.TempDir()
+	s.Fail("chat.delete", "cant_delete_message", 1)
+	if code := runCLI(t, cliArgs(dir, "-channel=general", "-messages")...); code != exitPartial {
+		t.Fatalf("first run: exit code %d, want %d", code, exitPartial)
+	}
+	if n := len(s.Messages("C00GENERAL")); n != 1 {
+		t.Fatalf("first run: %d messages left, want 1", n)
+	}
+	if code := runCLI(t, cliArgs(dir, "-retry-failed", filepath.Join(dir, "failed.json"))...); code != exitOK {
+		t.Fatalf("retry: exit code %d, want %d", code, exitOK)
+	}
+	if n := len(s.Messages("C00GENERAL")); n != 0 {
+		t.Errorf("retry: %d messages left, want 0", n)
+	}
+}
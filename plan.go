@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+const planVersion = 1
+
+// A plan is a reviewable list of everything a wipe will destroy.
+type plan struct {
+	Version  int
+	Created  time.Time
+	User     string
+	UserID   string
+	Channels []planChannel
+	Items    []wipe.Item
+	// Streamed is set for the journals of -stream runs, which lack the messages that were not fetched before an interrupt.
+	Streamed bool `json:",omitempty"`
+}
+
+type planChannel struct {
+	ID      string
+	Name    string
+	Members []string `json:",omitempty"`
+}
+
+func newPlan() plan {
+	p := plan{
+		Version: planVersion,
+		Created: time.Now().UTC(),
+		User:    state.Wiper.User(),
+		UserID:  state.Wiper.UserID(),
+	}
+	for _, c := range state.Channels {
+		p.Channels = append(p.Channels, planChannelFor(c))
+	}
+	return p
+}
+
+func planChannelFor(c slack.Channel) planChannel {
+	pc := planChannel{
+		ID:   c.ID,
+		Name: c.Name,
+	}
+	if c.IsIM || c.IsMpIM {
+		pc.Members = append(pc.Members, c.Members...)
+		sort.Strings(pc.Members)
+	}
+	return pc
+}
+
+func writePlan(path string, p plan) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readPlan(path string) (plan, error) {
+	var p plan
+	f, err := os.Open(path)
+	if err != nil {
+		return p, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&p); err != nil {
+		return p, err
+	}
+	if p.Version != planVersion {
+		return p, fmt.Errorf("unsupported plan version %d (want %d)", p.Version, planVersion)
+	}
+	return p, nil
+}
+
+// verifyPlan refuses plans made for another user, or for conversations that no longer resolve to the planned IDs.
+func verifyPlan(p plan) error {
+	if p.UserID != state.Wiper.UserID() {
+		return fmt.Errorf("plan was made for user @%s (%s), token belongs to @%s (%s)", p.User, p.UserID, state.Wiper.User(), state.Wiper.UserID())
+	}
+	var channelIDs map[string]string // by name, listed once on first use
+	for _, c := range p.Channels {
+		state.ChannelNames[c.ID] = c.Name
+		if len(c.Members) > 0 {
+			members, err := state.Wiper.Members(state.Context, c.ID)
+			if err != nil {
+				return fmt.Errorf("fetch conversation members for %s (%s): %v", c.Name, c.ID, err)
+			}
+			if !sameMembers(members, c.Members) {
+				return fmt.Errorf("members of conversation %s (%s) have changed", c.Name, c.ID)
+			}
+			continue
+		}
+		if channelIDs == nil {
+			channels, err := state.Wiper.ListChannels(state.Context)
+			if err != nil {
+				return fmt.Errorf("list channels: %v", err)
+			}
+			channelIDs = make(map[string]string, len(channels))
+			for _, channel := range channels {
+				channelIDs[channel.Name] = channel.ID
+			}
+		}
+		id, ok := channelIDs[c.Name]
+		if !ok {
+			return fmt.Errorf("channel not found: %q", c.Name)
+		}
+		if id != c.ID {
+			return fmt.Errorf("channel %q is now %s, plan was made for %s", c.Name, id, c.ID)
+		}
+	}
+	return nil
+}
+
+func sameMembers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, m := range a {
+		set[m] = true
+	}
+	for _, m := range b {
+		if !set[m] {
+			return false
+		}
+	}
+	return true
+}
+
+func applyPlan(path string) {
+	p, err := readPlan(path)
+	if err != nil {
+		fatalf("read plan %q: %v", path, err)
+	}
+	log.Printf("verifying plan %q (created %s)", path, p.Created.Format(time.RFC3339))
+	if err := verifyPlan(p); err != nil {
+		fatalf("verify plan %q: %v", path, err)
+	}
+	startJournal(p)
+	defer state.Journal.Close()
+	wipeItems(state.Context, p, p.Items, fmt.Sprintf("as planned in %q", path))
+}
+
+// stepTexts describe each of wipe.Steps in the approval prompt and on its progress bar.
+var stepTexts = map[wipe.Step]struct{ prompt, description string }{
+	{Kind: wipe.KindPin, Action: wipe.ActionRemove}:        {"remove %d pins", "removing pins"},
+	{Kind: wipe.KindStar, Action: wipe.ActionRemove}:       {"remove %d stars", "removing stars"},
+	{Kind: wipe.KindReaction, Action: wipe.ActionRemove}:   {"remove %d reactions", "removing reactions"},
+	{Kind: wipe.KindMessage, Action: wipe.ActionDelete}:    {"delete %d messages", "wiping messages"},
+	{Kind: wipe.KindMessage, Action: wipe.ActionRedact}:    {"redact %d messages", "redact messages"},
+	{Kind: wipe.KindMessage, Action: wipe.ActionOverwrite}: {"overwrite and delete %d messages", "overwriting messages"},
+	{Kind: wipe.KindFile, Action: wipe.ActionDelete}:       {"delete %d files", "wiping files"},
+}
+
+// groupItems groups the items by wipe step, in the order of wipe.Steps.
+func groupItems(items []wipe.Item) [][]wipe.Item {
+	groups := make([][]wipe.Item, len(wipe.Steps))
+items:
+	for _, item := range items {
+		for i, step := range wipe.Steps {
+			if item.Step() == step {
+				groups[i] = append(groups[i], item)
+				continue items
+			}
+		}
+		fatalf("unsupported item %+v", item)
+	}
+	return groups
+}
+
+func promptParts(groups [][]wipe.Item) []string {
+	var parts []string
+	for i, group := range groups {
+		if len(group) > 0 {
+			parts = append(parts, fmt.Sprintf(stepTexts[wipe.Steps[i]].prompt, len(group)))
+		}
+	}
+	return parts
+}
+
+// wipeItems asks for approval and then executes the given items.
+// Failed items are written to config.FailedFile, using the given plan header.
+func wipeItems(ctx context.Context, header plan, items []wipe.Item, source string) {
+	if len(items) == 0 {
+		log.Print("nothing to wipe")
+		return
+	}
+	groups := groupItems(items)
+	printSummary(items)
+	if !config.AutoApprove {
+		prompt := strings.Join(promptParts(groups), ", ")
+		if source != "" {
+			prompt += " " + source
+		}
+		if !approvalPrompt(prompt + "?") {
+			log.Print("aborted")
+			exit(exitAborted)
+		}
+	}
+	errs := executeItems(ctx, groups, nil)
+	finishRun(ctx, header, errs)
+}
+
+// executeItems runs the wipe steps (only those whose kind is accepted by include, if it is not nil).
+func executeItems(ctx context.Context, groups [][]wipe.Item, include func(kind string) bool) []error {
+	var errs []error
+	for i, group := range groups {
+		step := wipe.Steps[i]
+		if len(group) == 0 || (include != nil && !include(step.Kind)) {
+			continue
+		}
+		if err := wipeAll(ctx, group, stepTexts[step].description); err != nil {
+			errs = append(errs, fmt.Errorf("%s %ss: %v", step.Action, step.Kind, err))
+		}
+	}
+	return errs
+}
+
+// finishRun reports the results, and exits with exitAborted if the run was interrupted, or with exitPartial if anything failed.
+func finishRun(ctx context.Context, header plan, errs []error) {
+	state.Results.printChannels()
+	state.Results.printFailures()
+	writeFailures(config.FailedFile, header)
+	for _, err := range errs {
+		log.Print(err)
+	}
+	if ctx.Err() != nil {
+		reportInterrupted()
+		exit(exitAborted)
+	}
+	if len(errs) > 0 {
+		exit(exitPartial)
+	}
+}
+
+// printSummary prints the number of items per channel, kind and action.
+func printSummary(items []wipe.Item) {
+	type what struct{ Action, Kind string }
+	var channels []string
+	counts := make(map[string]map[what]int)
+	for _, item := range items {
+		if counts[item.Channel] == nil {
+			counts[item.Channel] = make(map[what]int)
+			channels = append(channels, item.Channel)
+		}
+		counts[item.Channel][what{item.Action, item.Kind}]++
+	}
+	for _, id := range channels {
+		var parts []string
+		for w, n := range counts[id] {
+			parts = append(parts, fmt.Sprintf("%s %d %ss", w.Action, n, w.Kind))
+		}
+		sort.Strings(parts)
+		fmt.Fprintf(out, "%s: %s\n", channelName(id), strings.Join(parts, ", "))
+	}
+}
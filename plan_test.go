@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+func TestStepTexts(t *testing.T) {
+	for _, step := range wipe.Steps {
+		if _, ok := stepTexts[step]; !ok {
+			t.Errorf("no texts for step %+v", step)
+		}
+	}
+	if len(stepTexts) != len(wipe.Steps) {
+		t.Errorf("%d step texts for %d steps", len(stepTexts), len(wipe.Steps))
+	}
+}
+
+func TestGroupItems(t *testing.T) {
+	items := []wipe.Item{
+		{Kind: wipe.KindFile, Action: wipe.ActionDelete, File: "F1"},
+		{Kind: wipe.KindMessage, Action: wipe.ActionDelete, Timestamp: "1.1"},
+		{Kind: wipe.KindStar, Action: wipe.ActionRemove, Timestamp: "1.1"},
+		{Kind: wipe.KindPin, Action: wipe.ActionRemove, Timestamp: "1.1"},
+	}
+	var order []wipe.Step
+	for _, group := range groupItems(items) {
+		for _, item := range group {
+			order = append(order, item.Step())
+		}
+	}
+	for i, step := range []wipe.Step{items[3].Step(), items[2].Step(), items[1].Step(), items[0].Step()} {
+		if order[i] != step {
+			t.Fatalf("grouped order = %v, want pins, stars, messages, files", order)
+		}
+	}
+}
+
+func TestApplyListsChannelsOnce(t *testing.T) {
+	s := newWorkspace(t)
+	s.AddChannel("C000RANDOM", "random", false, testUser)
+	s.Post("C000RANDOM", testUser, "random secret", time.Now().Add(-time.Hour), "")
+	dir := t.TempDir()
+	p := plan{Version: planVersion, User: "me", UserID: testUser}
+	for _, c := range []planChannel{{ID: "C00GENERAL", Name: "general"}, {ID: "C000RANDOM", Name: "random"}} {
+		p.Channels = append(p.Channels, c)
+		for _, m := range s.Messages(c.ID) {
+			p.Items = append(p.Items, wipe.Item{Kind: wipe.KindMessage, Action: wipe.ActionDelete, Channel: c.ID, Timestamp: m.Timestamp})
+		}
+	}
+	path := filepath.Join(dir, "plan.json")
+	if err := writePlan(path, p); err != nil {
+		t.Fatalf("writePlan: %v", err)
+	}
+	if code := runCLI(t, cliArgs(dir, "-apply", path)...); code != exitOK {
+		t.Fatalf("exit code %d, want %d", code, exitOK)
+	}
+	if n := s.Calls("conversations.list"); n != 1 {
+		t.Errorf("conversations.list was called %d times, want 1", n)
+	}
+	if n := len(s.Messages("C00GENERAL")) + len(s.Messages("C000RANDOM")); n != 0 {
+		t.Errorf("%d messages left, want 0", n)
+	}
+}
+
+func TestApplyRefusesRenamedChannel(t *testing.T) {
+	newWorkspace(t)
+	dir := t.TempDir()
+	p := plan{Version: planVersion, User: "me", UserID: testUser, Channels: []planChannel{{ID: "C0000OTHER", Name: "general"}}}
+	path := filepath.Join(dir, "plan.json")
+	if err := writePlan(path, p); err != nil {
+		t.Fatalf("writePlan: %v", err)
+	}
+	if code := runCLI(t, cliArgs(dir, "-apply", path)...); code != exitFatal {
+		t.Errorf("exit code %d, want %d", code, exitFatal)
+	}
+}
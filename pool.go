@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/schollz/progressbar"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+// progress is satisfied by *progressbar.ProgressBar.
+type progress interface {
+	Add(int) error
+}
+
+// wipeAll wipes the given items using state.Wiper, showing a progress bar.
+func wipeAll(ctx context.Context, items []wipe.Item, description string) error {
+	journalAdd(items)
+	source := make(chan wipe.Item, len(items))
+	for _, item := range items {
+		source <- item
+	}
+	close(source)
+	bar := progressbar.NewOptions(len(items), progressbar.OptionSetDescription(description), progressbar.OptionSetWriter(out))
+	bar.RenderBlank()
+	state.Progress = bar
+	done, failed := state.Wiper.Run(ctx, source)
+	bar.Finish()
+	fmt.Fprintln(out)
+	switch {
+	case failed > 0 && done+failed < len(items):
+		return fmt.Errorf("%d of %d failed, %d not attempted", failed, len(items), len(items)-done-failed)
+	case failed > 0:
+		return fmt.Errorf("%d of %d failed", failed, len(items))
+	case done < len(items):
+		return fmt.Errorf("%d of %d not attempted", len(items)-done, len(items))
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+// Exit codes.
+const (
+	exitOK      = 0
+	exitFatal   = 1
+	exitConfig  = 2
+	exitPartial = 3
+	exitAborted = 4
+)
+
+var exitStatuses = map[int]string{
+	exitOK:      "success",
+	exitFatal:   "fatal",
+	exitConfig:  "config_error",
+	exitPartial: "partial_failure",
+	exitAborted: "aborted",
+}
+
+// out receives the human-readable output (progress bars and summaries).
+// With -log-format=json it is discarded, and stderr carries only JSON lines.
+var out io.Writer = os.Stdout
+
+var logEncoder = struct {
+	sync.Mutex
+	enc *json.Encoder
+}{enc: json.NewEncoder(os.Stderr)}
+
+// logEvent writes a structured event with -log-format=json, and does nothing otherwise.
+func logEvent(event string, fields map[string]interface{}) {
+	if config.LogFormat != "json" {
+		return
+	}
+	fields["event"] = event
+	fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	logEncoder.Lock()
+	defer logEncoder.Unlock()
+	logEncoder.enc.Encode(fields)
+}
+
+// jsonLogWriter turns the lines written by the log package into "log" events.
+type jsonLogWriter struct{}
+
+func (jsonLogWriter) Write(p []byte) (int, error) {
+	logEvent("log", map[string]interface{}{"message": strings.TrimSuffix(string(p), "\n")})
+	return len(p), nil
+}
+
+func setLogFormat(format string) error {
+	switch format {
+	case "text":
+	case "json":
+		log.SetOutput(jsonLogWriter{})
+		log.SetFlags(0)
+		out = ioutil.Discard
+	default:
+		return fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+	return nil
+}
+
+// resolved records the name of a conversation that is about to be wiped.
+func resolved(c slack.Channel) {
+	state.ChannelNames[c.ID] = c.Name
+	logEvent("conversation", map[string]interface{}{"id": c.ID, "name": c.Name})
+}
+
+// rateLimitTally counts the rate limit responses per method, and the time spent waiting for them.
+type rateLimitTally struct {
+	mu    sync.Mutex
+	waits map[string]*rateLimitWait
+}
+
+type rateLimitWait struct {
+	Method      string
+	Waits       int
+	WaitSeconds float64
+}
+
+func (t *rateLimitTally) add(method string, retryAfter time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.waits == nil {
+		t.waits = make(map[string]*rateLimitWait)
+	}
+	w := t.waits[method]
+	if w == nil {
+		w = &rateLimitWait{Method: method}
+		t.waits[method] = w
+	}
+	w.Waits++
+	w.WaitSeconds += retryAfter.Seconds()
+}
+
+func (t *rateLimitTally) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.waits = nil
+}
+
+func (t *rateLimitTally) list() []rateLimitWait {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	waits := make([]rateLimitWait, 0, len(t.waits))
+	for _, w := range t.waits {
+		waits = append(waits, *w)
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i].Method < waits[j].Method })
+	return waits
+}
+
+// A runReport summarizes a run, for -report and the JSON summary event.
+type runReport struct {
+	Status          string
+	ExitCode        int
+	Started         time.Time
+	Finished        time.Time
+	DurationSeconds float64
+	User            string `json:",omitempty"`
+	Targets         []targetCount
+	Failures        []failureCount
+	RateLimits      []rateLimitWait
+}
+
+type targetCount struct {
+	Kind   string
+	Action string
+	Done   int
+	Failed int
+}
+
+type failureCount struct {
+	Code  string
+	Count int
+}
+
+func newReport(code int) runReport {
+	finished := time.Now().UTC()
+	r := runReport{
+		Status:          exitStatuses[code],
+		ExitCode:        code,
+		Started:         state.Started,
+		Finished:        finished,
+		DurationSeconds: finished.Sub(state.Started).Seconds(),
+		Targets:         []targetCount{},
+		Failures:        []failureCount{},
+		RateLimits:      state.RateLimits.list(),
+	}
+	if state.Wiper != nil {
+		r.User = state.Wiper.User()
+	}
+	if state.Results != nil {
+		r.Targets, r.Failures = state.Results.counts()
+	}
+	return r
+}
+
+// counts returns the number of done and failed items per kind and action, and the number of failures per error code.
+func (c *resultCollector) counts() ([]targetCount, []failureCount) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	type target struct{ kind, action string }
+	targets := make(map[target]*targetCount)
+	codes := make(map[string]int)
+	for _, r := range c.results {
+		t := target{r.Item.Kind, r.Item.Action}
+		n := targets[t]
+		if n == nil {
+			n = &targetCount{Kind: t.kind, Action: t.action}
+			targets[t] = n
+		}
+		if r.Err != nil {
+			n.Failed++
+			codes[r.Code]++
+		} else {
+			n.Done++
+		}
+	}
+	targetCounts := []targetCount{}
+	for _, n := range targets {
+		targetCounts = append(targetCounts, *n)
+	}
+	sort.Slice(targetCounts, func(i, j int) bool {
+		a, b := targetCounts[i], targetCounts[j]
+		return a.Kind < b.Kind || (a.Kind == b.Kind && a.Action < b.Action)
+	})
+	failureCounts := []failureCount{}
+	for code, n := range codes {
+		failureCounts = append(failureCounts, failureCount{code, n})
+	}
+	sort.Slice(failureCounts, func(i, j int) bool { return failureCounts[i].Count > failureCounts[j].Count })
+	return targetCounts, failureCounts
+}
+
+// writeReport writes the report as CSV if the path ends in .csv, and as JSON otherwise.
+func writeReport(path string, r runReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = writeReportCSV(f, r)
+	} else {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeReportCSV writes the report as metric,key,value rows.
+func writeReportCSV(w io.Writer, r runReport) error {
+	seconds := func(s float64) string { return strconv.FormatFloat(s, 'f', 3, 64) }
+	rows := [][]string{
+		{"metric", "key", "value"},
+		{"status", "", r.Status},
+		{"exit_code", "", strconv.Itoa(r.ExitCode)},
+		{"started", "", r.Started.Format(time.RFC3339)},
+		{"finished", "", r.Finished.Format(time.RFC3339)},
+		{"duration_seconds", "", seconds(r.DurationSeconds)},
+	}
+	for _, t := range r.Targets {
+		target := t.Kind + "/" + t.Action
+		rows = append(rows, []string{"done", target, strconv.Itoa(t.Done)}, []string{"failed", target, strconv.Itoa(t.Failed)})
+	}
+	for _, f := range r.Failures {
+		rows = append(rows, []string{"failures", f.Code, strconv.Itoa(f.Count)})
+	}
+	for _, l := range r.RateLimits {
+		rows = append(rows, []string{"rate_limit_waits", l.Method, strconv.Itoa(l.Waits)}, []string{"rate_limit_wait_seconds", l.Method, seconds(l.WaitSeconds)})
+	}
+	cw := csv.NewWriter(w)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+// exit writes the summary event and the report (if requested), and exits with the given code.
+func exit(code int) {
+	r := newReport(code)
+	logEvent("summary", map[string]interface{}{"report": r})
+	if config.Report != "" {
+		if err := writeReport(config.Report, r); err != nil {
+			log.Printf("write report %q: %v", config.Report, err)
+		}
+	}
+	osExit(code)
+}
+
+// osExit is os.Exit, except in tests.
+var osExit = os.Exit
+
+// fatalf logs the error and exits with exitFatal.
+func fatalf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	exit(exitFatal)
+}
+
+// listFailedf is fatalf for errors in listing what to wipe: if the run was interrupted, it exits with exitAborted instead.
+func listFailedf(format string, v ...interface{}) {
+	if state.Context != nil && state.Context.Err() != nil {
+		reportInterrupted()
+		exit(exitAborted)
+	}
+	fatalf(format, v...)
+}
+
+// configErrorf logs the error in the flags or files given, and exits with exitConfig.
+func configErrorf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+	exit(exitConfig)
+}
+
+// itemEvent logs the outcome of an item.
+func itemEvent(item wipe.Item, attempts int, err error) {
+	fields := map[string]interface{}{
+		"kind":     item.Kind,
+		"action":   item.Action,
+		"channel":  item.Channel,
+		"attempts": attempts,
+	}
+	for name, value := range map[string]string{"ts": item.Timestamp, "file": item.File, "reaction": item.Reaction} {
+		if value != "" {
+			fields[name] = value
+		}
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		fields["code"] = wipe.ErrorCode(err)
+		if phase := wipe.Phase(err); phase != "" {
+			fields["phase"] = phase
+		}
+	}
+	logEvent("item", fields)
+}
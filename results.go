@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+type itemResult struct {
+	Item     wipe.Item
+	Err      error
+	Code     string
+	Phase    string
+	Attempts int
+}
+
+// resultCollector records the outcome of every wiped item.
+type resultCollector struct {
+	mu      sync.Mutex
+	results map[string]*itemResult
+	order   []string
+}
+
+func newResultCollector() *resultCollector {
+	return &resultCollector{results: make(map[string]*itemResult)}
+}
+
+func (c *resultCollector) record(item wipe.Item, attempts int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := item.Key()
+	if _, ok := c.results[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.results[key] = &itemResult{
+		Item:     item,
+		Err:      err,
+		Code:     wipe.ErrorCode(err),
+		Phase:    wipe.Phase(err),
+		Attempts: attempts,
+	}
+}
+
+func (c *resultCollector) failures() []itemResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var failed []itemResult
+	for _, key := range c.order {
+		if r := c.results[key]; r.Err != nil {
+			failed = append(failed, *r)
+		}
+	}
+	return failed
+}
+
+// printChannels prints the number of done and failed items per channel and kind.
+func (c *resultCollector) printChannels() {
+	type counts struct{ done, failed int }
+	c.mu.Lock()
+	byChannel := make(map[string]map[string]*counts)
+	for _, r := range c.results {
+		if byChannel[r.Item.Channel] == nil {
+			byChannel[r.Item.Channel] = make(map[string]*counts)
+		}
+		n := byChannel[r.Item.Channel][r.Item.Kind]
+		if n == nil {
+			n = &counts{}
+			byChannel[r.Item.Channel][r.Item.Kind] = n
+		}
+		if r.Err != nil {
+			n.failed++
+		} else {
+			n.done++
+		}
+	}
+	c.mu.Unlock()
+	ids := make([]string, 0, len(byChannel))
+	for id := range byChannel {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return channelName(ids[i]) < channelName(ids[j]) })
+	for _, id := range ids {
+		kinds := make([]string, 0, len(byChannel[id]))
+		for kind := range byChannel[id] {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		var parts []string
+		for _, kind := range kinds {
+			n := byChannel[id][kind]
+			parts = append(parts, fmt.Sprintf("%ss: %d done, %d failed", kind, n.done, n.failed))
+		}
+		fmt.Fprintf(out, "%s: %s\n", channelName(id), strings.Join(parts, "; "))
+	}
+}
+
+// printFailures prints a table of failures grouped by error code.
+func (c *resultCollector) printFailures() {
+	failed := c.failures()
+	if len(failed) == 0 {
+		return
+	}
+	byCode := make(map[string][]itemResult)
+	var codes []string
+	for _, r := range failed {
+		if byCode[r.Code] == nil {
+			codes = append(codes, r.Code)
+		}
+		byCode[r.Code] = append(byCode[r.Code], r)
+	}
+	sort.SliceStable(codes, func(i, j int) bool { return len(byCode[codes[i]]) > len(byCode[codes[j]]) })
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ERROR\tITEMS\tEXAMPLE")
+	for _, code := range codes {
+		example := byCode[code][0]
+		fmt.Fprintf(w, "%s\t%d\t%s in %s: %v\n", code, len(byCode[code]), example.Item.Kind, channelName(example.Item.Channel), example.Err)
+	}
+	w.Flush()
+	printPhaseFailures(failed)
+}
+
+// overwriteOutcomes describes the state of a message whose overwrite failed in the given phase.
+var overwriteOutcomes = []struct{ phase, outcome string }{
+	{wipe.PhaseOverwrite, "could not be overwritten, and still show the original text (not deleted)"},
+	{wipe.PhaseConfirm, "were overwritten, but the edit could not be confirmed (not deleted)"},
+	{wipe.PhaseDelete, "were overwritten with redacted text, but could not be deleted"},
+}
+
+// printPhaseFailures tells how far the failed overwrites got.
+func printPhaseFailures(failed []itemResult) {
+	counts := make(map[string]int)
+	for _, r := range failed {
+		counts[r.Phase]++
+	}
+	for _, o := range overwriteOutcomes {
+		if n := counts[o.phase]; n > 0 {
+			fmt.Fprintf(out, "%d messages %s\n", n, o.outcome)
+		}
+	}
+}
+
+func recordResult(item wipe.Item, attempts int, err error) {
+	journalMark(item, err)
+	state.Results.record(item, attempts, err)
+}
+
+// writeFailures writes the failed items as a plan that can be run with -retry-failed.
+func writeFailures(path string, header plan) {
+	failed := state.Results.failures()
+	if len(failed) == 0 || path == "" {
+		return
+	}
+	header.Created = time.Now().UTC()
+	header.Items = make([]wipe.Item, 0, len(failed))
+	for _, r := range failed {
+		header.Items = append(header.Items, r.Item)
+	}
+	if err := writePlan(path, header); err != nil {
+		log.Printf("write failed items to %q: %v", path, err)
+		return
+	}
+	log.Printf("wrote %d failed items to %q (retry them with -retry-failed=%s)", len(failed), path, path)
+}
+
+// reportInterrupted tells how far an interrupted run got and how to continue it.
+func reportInterrupted() {
+	if state.Journal == nil {
+		log.Print("interrupted; the remaining items were not attempted")
+		return
+	}
+	counts := state.Journal.counts()
+	log.Printf("interrupted: %d done, %d failed, %d not attempted (recorded in journal %q)", counts[itemDone], counts[itemFailed], counts[itemPending], config.Journal)
+	log.Printf("continue with -resume")
+	if state.Journal.header.Streamed {
+		log.Printf("messages that were not fetched yet are not in the journal: once -resume is done, run the -stream command again to find them")
+	}
+}
+
+func channelName(id string) string {
+	if name, ok := state.ChannelNames[id]; ok {
+		return name
+	}
+	return id
+}
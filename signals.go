@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptContext returns a context that is canceled on the first SIGINT or SIGTERM.
+// A second signal exits immediately.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("%v: stopping after the requests in flight (repeat to quit immediately)", sig)
+		cancel()
+		sig = <-signals
+		log.Printf("%v: quitting", sig)
+		exit(exitAborted)
+	}()
+	return ctx
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+// streamWipe wipes the given items like wipe.Items, but fetches and wipes the user's messages page by page.
+// Since the messages are not listed up front, approval is asked for an estimated number of messages.
+func streamWipe(ctx context.Context, header plan, items []wipe.Item) {
+	groups := groupItems(items)
+	var estimate, direct int
+	if config.WipeMessages {
+		for _, c := range state.Channels {
+			if c.IsIM || c.IsMpIM {
+				direct++
+				continue
+			}
+			n, err := state.Wiper.MessageTotal(ctx, c)
+			if err != nil {
+				listFailedf("count messages in channel %q: %v", c.Name, err)
+			}
+			estimate += n
+		}
+	}
+	if len(items) > 0 {
+		printSummary(items)
+	}
+	if !config.AutoApprove {
+		parts := promptParts(groups)
+		if config.WipeMessages {
+			verb := "delete"
+			switch {
+			case config.Redact:
+				verb = "redact"
+			case config.Overwrite:
+				verb = "overwrite and delete"
+			}
+			messages := fmt.Sprintf("%s up to %d messages", verb, estimate)
+			if direct > 0 {
+				messages += fmt.Sprintf(" (plus all your messages in %d direct conversations)", direct)
+			}
+			parts = append(parts, messages)
+		}
+		if len(parts) == 0 {
+			log.Print("nothing to wipe")
+			return
+		}
+		if !approvalPrompt(strings.Join(parts, ", ") + "?") {
+			log.Print("aborted")
+			exit(exitAborted)
+		}
+	}
+	notFiles := func(kind string) bool { return kind != wipe.KindFile }
+	errs := executeItems(ctx, groups, notFiles)
+	if config.WipeMessages {
+		if err := streamMessages(ctx, estimate); err != nil {
+			errs = append(errs, fmt.Errorf("stream messages: %v", err))
+		}
+	}
+	errs = append(errs, executeItems(ctx, groups, func(kind string) bool { return !notFiles(kind) })...)
+	finishRun(ctx, header, errs)
+}
+
+// streamMessages fetches the user's messages in state.Channels and wipes each page while the next one is fetched.
+func streamMessages(ctx context.Context, estimate int) error {
+	source := make(chan wipe.Item, 100)
+	fetchErr := make(chan error, 1)
+	// set before the fetching starts, since handleEvent reads it from the fetching goroutine
+	state.Streaming = true
+	defer func() { state.Streaming = false }()
+	go func() {
+		defer close(source)
+		for _, c := range state.Channels {
+			c := c
+			err := state.Wiper.MessagePages(ctx, c, func(messages []slack.SearchMessage) error {
+				items := state.Wiper.MessageItems(c, messages)
+				journalAdd(items)
+				for _, item := range items {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case source <- item:
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				fetchErr <- fmt.Errorf("fetch messages for %q: %v", c.Name, err)
+				return
+			}
+		}
+		fetchErr <- nil
+	}()
+	state.Progress = &streamProgress{description: "wiping messages", estimate: estimate}
+	done, failed := state.Wiper.Run(ctx, source)
+	fmt.Fprintln(out)
+	if err := <-fetchErr; err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d failed", failed, done+failed)
+	}
+	return nil
+}
+
+// streamProgress reports progress against an estimated total.
+type streamProgress struct {
+	description string
+	estimate    int
+	n           int
+}
+
+func (p *streamProgress) Add(n int) error {
+	p.n += n
+	if p.estimate > 0 {
+		fmt.Fprintf(out, "\r%s: %d (of about %d)", p.description, p.n, p.estimate)
+	} else {
+		fmt.Fprintf(out, "\r%s: %d", p.description, p.n)
+	}
+	return nil
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+// watchSeen marks a watch store record that only says until when messages were seen.
+const watchSeen = "seen"
+
+// catchUpMargin is how far before the last seen time a catch-up after a (re)connect starts looking for messages.
+const catchUpMargin = time.Minute
+
+// A message that could not be wiped stays pending, and is retried after a delay that doubles with each failure, up to a maximum.
+const (
+	watchRetryMin = time.Minute
+	watchRetryMax = 6 * time.Hour
+)
+
+// watchRetryDelay returns how long to wait before retrying a message that failed the given number of times.
+func watchRetryDelay(failures int) time.Duration {
+	d := watchRetryMin
+	for i := 1; i < failures && d < watchRetryMax; i++ {
+		d *= 2
+	}
+	if d > watchRetryMax {
+		d = watchRetryMax
+	}
+	return d
+}
+
+// A ttlRule gives the time-to-live of the messages in the conversations whose name matches the pattern.
+type ttlRule struct {
+	pattern string
+	ttl     time.Duration
+}
+
+// parseTTLs parses a comma-separated list of PATTERN=AGE rules. A bare AGE applies to all conversations.
+func parseTTLs(s string) ([]ttlRule, error) {
+	var rules []ttlRule
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		pattern, age := "*", rule
+		if i := strings.LastIndex(rule, "="); i >= 0 {
+			pattern, age = strings.TrimPrefix(strings.TrimSpace(rule[:i]), "#"), strings.TrimSpace(rule[i+1:])
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		ttl, err := parseAge(age)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ttlRule{pattern, ttl})
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no time-to-live given")
+	}
+	return rules, nil
+}
+
+// watchName is the name -ttl patterns are matched against: @username for IMs, the conversation name otherwise.
+func watchName(c slack.Channel) string {
+	if c.IsIM {
+		return "@" + state.Wiper.UserName(c.User)
+	}
+	return c.Name
+}
+
+// ttlFor returns the time-to-live given by the first rule that matches the conversation.
+func ttlFor(c slack.Channel) (time.Duration, bool) {
+	name := watchName(c)
+	for _, rule := range state.TTLs {
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			return rule.ttl, true
+		}
+	}
+	return 0, false
+}
+
+type watchRecord struct {
+	Item     wipe.Item
+	State    string
+	Due      time.Time
+	Seen     time.Time
+	Failures int    `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// A watchStore is an append-only JSON-lines log of the messages scheduled by -watch, and of how far messages were seen.
+// It is compacted to the pending messages when it is opened, so that a restart picks up where the last run left off.
+type watchStore struct {
+	mu         sync.Mutex
+	file       *jsonlFile
+	seen       time.Time
+	pending    map[string]watchRecord
+	dispatched map[string]bool
+}
+
+func openWatchStore(storePath string) (*watchStore, error) {
+	s := &watchStore{
+		pending:    make(map[string]watchRecord),
+		dispatched: make(map[string]bool),
+	}
+	err := readJSONL(storePath, nil, func(line []byte) error {
+		var r watchRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			return err
+		}
+		switch r.State {
+		case watchSeen:
+			if r.Seen.After(s.seen) {
+				s.seen = r.Seen
+			}
+		case itemPending:
+			s.pending[r.Item.Key()] = r
+		default:
+			delete(s.pending, r.Item.Key())
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	tmp := storePath + ".tmp"
+	file, err := createJSONL(tmp)
+	if err != nil {
+		return nil, err
+	}
+	if !s.seen.IsZero() {
+		if err := file.append(watchRecord{State: watchSeen, Seen: s.seen}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	for _, r := range s.sortedPending() {
+		if err := file.append(r); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, storePath); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *watchStore) sortedPending() []watchRecord {
+	records := make([]watchRecord, 0, len(s.pending))
+	for _, r := range s.pending {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Due.Before(records[j].Due) })
+	return records
+}
+
+func (s *watchStore) write(r watchRecord) error {
+	if err := s.file.append(r); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// schedule adds the item, due at the given time, unless it is already pending.
+func (s *watchStore) schedule(item wipe.Item, due time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := item.Key()
+	if _, ok := s.pending[key]; ok {
+		return false, nil
+	}
+	r := watchRecord{Item: item, State: itemPending, Due: due}
+	s.pending[key] = r
+	return true, s.write(r)
+}
+
+// takeDue returns the pending items that are due at now and were not taken before, earliest first.
+func (s *watchStore) takeDue(now time.Time) []wipe.Item {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []wipe.Item
+	for _, r := range s.sortedPending() {
+		if r.Due.After(now) {
+			break
+		}
+		if key := r.Item.Key(); !s.dispatched[key] {
+			s.dispatched[key] = true
+			items = append(items, r.Item)
+		}
+	}
+	return items
+}
+
+// finish records that the item was wiped. If it failed, it stays pending, and is due again after watchRetryDelay;
+// the returned time is when.
+func (s *watchStore) finish(item wipe.Item, err error, now time.Time) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := item.Key()
+	delete(s.dispatched, key)
+	if err == nil {
+		delete(s.pending, key)
+		return time.Time{}, s.write(watchRecord{Item: item, State: itemDone})
+	}
+	r := s.pending[key]
+	r.Item, r.State = item, itemPending
+	r.Failures++
+	r.Due = now.Add(watchRetryDelay(r.Failures))
+	r.Error = err.Error()
+	s.pending[key] = r
+	return r.Due, s.write(r)
+}
+
+// markSeen records that all messages posted before t were seen.
+func (s *watchStore) markSeen(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !t.After(s.seen) {
+		return nil
+	}
+	s.seen = t
+	return s.write(watchRecord{State: watchSeen, Seen: t})
+}
+
+func (s *watchStore) lastSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seen
+}
+
+func (s *watchStore) pendingCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *watchStore) Close() error {
+	return s.file.Close()
+}
+
+type watchedConversation struct {
+	slack.Channel
+	ttl time.Duration
+}
+
+// watch listens for the user's messages in the given conversations and wipes each one once its time-to-live has passed,
+// until ctx is done. Pending messages are kept in the watch store, so they are wiped by the next run if this one stops first.
+// After each (re)connect, messages posted since the last one seen are caught up with.
+func watch(ctx context.Context, channels []slack.Channel) {
+	store, err := openWatchStore(config.WatchStore)
+	if err != nil {
+		fatalf("open watch store %q: %v", config.WatchStore, err)
+	}
+	defer store.Close()
+	state.WatchStore = store
+	watched := make(map[string]watchedConversation)
+	for _, c := range channels {
+		ttl, ok := ttlFor(c)
+		if !ok {
+			log.Printf("not watching %s (no -ttl rule matches %q)", c.Name, watchName(c))
+			continue
+		}
+		watched[c.ID] = watchedConversation{c, ttl}
+		log.Printf("watching %s (time-to-live %v)", c.Name, ttl)
+	}
+	if len(watched) == 0 {
+		fatalf("no conversations to watch")
+	}
+	if n := store.pendingCount(); n > 0 {
+		log.Printf("%d messages pending from earlier runs (in %q)", n, config.WatchStore)
+	}
+
+	source := make(chan wipe.Item)
+	wiped := make(chan struct{})
+	go func() {
+		defer close(wiped)
+		state.Wiper.Run(ctx, source)
+	}()
+	go func() {
+		defer close(source)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, item := range store.takeDue(now) {
+					select {
+					case <-ctx.Done():
+						return
+					case source <- item:
+					}
+				}
+			}
+		}
+	}()
+
+	seenTicker := time.NewTicker(time.Minute)
+	defer seenTicker.Stop()
+	connected := false
+	for {
+		select {
+		case <-ctx.Done():
+			<-wiped
+			log.Printf("stopped watching; %d messages pending (wiped by the next -watch run)", store.pendingCount())
+			return
+		case now := <-seenTicker.C:
+			if connected {
+				if err := store.markSeen(now); err != nil {
+					log.Printf("watch store: %v", err)
+				}
+			}
+		case event := <-state.RTM.IncomingEvents:
+			switch e := event.Data.(type) {
+			case *slack.ConnectedEvent:
+				log.Printf("connected to Slack (connection %d)", e.ConnectionCount)
+				connected = true
+				go catchUp(ctx, store, watched, time.Now())
+			case *slack.DisconnectedEvent:
+				log.Print("disconnected from Slack, reconnecting")
+				connected = false
+			case *slack.InvalidAuthEvent:
+				fatalf("real-time API: invalid token")
+			case *slack.MessageEvent:
+				c, ok := watched[e.Channel]
+				if !ok || e.User != state.Wiper.UserID() || !watchedSubTypes[e.SubType] {
+					continue
+				}
+				scheduleMessages(store, c, []slack.SearchMessage{{
+					Type:        e.Type,
+					Channel:     slack.CtxChannel{ID: c.ID, Name: c.Name},
+					User:        e.User,
+					Timestamp:   e.Timestamp,
+					Text:        e.Text,
+					Attachments: e.Attachments,
+				}})
+			}
+		}
+	}
+}
+
+// watchedSubTypes are the message subtypes of messages the user posted (edits and deletions are not).
+var watchedSubTypes = map[string]bool{
+	"":                 true,
+	"me_message":       true,
+	"thread_broadcast": true,
+	"file_share":       true,
+}
+
+// catchUp schedules the messages posted since the last one seen (before a disconnect or restart), and then marks now as seen.
+// On the very first run, there is nothing to catch up with. IM history is only read back to the last seen time,
+// so that a reconnect does not walk every thread ever started; replies posted meanwhile in older threads are missed.
+func catchUp(ctx context.Context, store *watchStore, watched map[string]watchedConversation, now time.Time) {
+	if seen := store.lastSeen(); !seen.IsZero() {
+		since := seen.Add(-catchUpMargin)
+		w := state.Wiper.With(wipe.WithDateRange(since, time.Time{}), wipe.WithOldest(since))
+		for _, c := range watched {
+			messages, err := w.Messages(ctx, c.Channel)
+			if err != nil {
+				log.Printf("catch up with %s: %v", c.Name, err)
+				return
+			}
+			scheduleMessages(store, c, messages)
+		}
+		log.Printf("caught up with messages since %s", since.Local().Format(time.RFC3339))
+	}
+	if err := store.markSeen(now); err != nil {
+		log.Printf("watch store: %v", err)
+	}
+}
+
+func scheduleMessages(store *watchStore, c watchedConversation, messages []slack.SearchMessage) {
+	for _, item := range state.Wiper.MessageItems(c.Channel, state.Wiper.FilterMessages(messages)) {
+		due := wipe.TimestampTime(item.Timestamp).Add(c.ttl)
+		added, err := store.schedule(item, due)
+		if err != nil {
+			log.Printf("watch store: %v", err)
+		}
+		if added {
+			log.Printf("%s: %s message %s at %s", c.Name, item.Action, item.Timestamp, due.Local().Format(time.RFC3339))
+		}
+	}
+}
+
+// watchFinished records the outcome of a scheduled item.
+func watchFinished(item wipe.Item, attempts int, err error) {
+	state.Results.record(item, attempts, err)
+	retry, storeErr := state.WatchStore.finish(item, err, time.Now())
+	if err != nil {
+		log.Printf("%s: %s message %s failed: %v (retrying at %s)", channelName(item.Channel), item.Action, item.Timestamp, err, retry.Local().Format(time.RFC3339))
+	} else {
+		log.Printf("%s: %s message %s done", channelName(item.Channel), item.Action, item.Timestamp)
+	}
+	if storeErr != nil {
+		log.Printf("watch store: %v", storeErr)
+	}
+}
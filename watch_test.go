@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgreben/slack-wipe/wipe"
+)
+
+func TestWatchStoreRetriesFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch")
+	s, err := openWatchStore(path)
+	if err != nil {
+		t.Fatalf("openWatchStore: %v", err)
+	}
+	item := wipe.Item{Kind: wipe.KindMessage, Action: wipe.ActionDelete, Channel: "C00GENERAL", Timestamp: "1500000000.000100"}
+	now := time.Now()
+	if _, err := s.schedule(item, now); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	for failures := 1; failures <= 2; failures++ {
+		if due := s.takeDue(now); len(due) != 1 {
+			t.Fatalf("failure %d: takeDue = %v, want the item", failures, due)
+		}
+		retry, err := s.finish(item, errors.New("internal_error"), now)
+		if err != nil {
+			t.Fatalf("finish: %v", err)
+		}
+		if want := now.Add(watchRetryDelay(failures)); !retry.Equal(want) {
+			t.Errorf("failure %d: retry at %v, want %v", failures, retry, want)
+		}
+		if due := s.takeDue(retry.Add(-time.Second)); len(due) != 0 {
+			t.Errorf("failure %d: due before the retry time: %v", failures, due)
+		}
+		now = retry
+	}
+	s.Close()
+
+	s, err = openWatchStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if r := s.pending[item.Key()]; r.Failures != 2 || !r.Due.Equal(now) {
+		t.Errorf("reopened: pending %+v, want 2 failures, due at %v", r, now)
+	}
+	if _, err := s.finish(item, nil, now); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if n := s.pendingCount(); n != 0 {
+		t.Errorf("%d pending after success, want 0", n)
+	}
+}
+
+func TestWatchRetryDelay(t *testing.T) {
+	for failures, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 100: watchRetryMax} {
+		if got := watchRetryDelay(failures); got != want {
+			t.Errorf("watchRetryDelay(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func TestOpenWatchStoreKeepsCorruptFile(t *testing.T) {
+	content := "{\"State\":\"seen\",\"Seen\":\"2020-01-01T00:00:00Z\"}\nxyz\n{\"State\":\"seen\",\"Seen\":\"2020-01-02T00:00:00Z\"}\n"
+	path := writeTestFile(t, content)
+	if _, err := openWatchStore(path); err == nil {
+		t.Fatal("openWatchStore: no error for a corrupt line in the middle")
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != content {
+		t.Errorf("the corrupt store was rewritten: %q", b)
+	}
+}
@@ -0,0 +1,269 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+func (w *Wiper) listConversations(ctx context.Context, types ...string) ([]slack.Channel, error) {
+	var channels []slack.Channel
+	first := true
+	cursor := ""
+	for first || cursor != "" {
+		first = false
+		var moreChannels []slack.Channel
+		var nextCursor string
+		err := w.call(ctx, "conversations.list", func() (err error) {
+			moreChannels, nextCursor, err = w.client.GetConversationsContext(ctx, &slack.GetConversationsParameters{
+				Cursor:          cursor,
+				Types:           types,
+				ExcludeArchived: "false",
+				Limit:           1000,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, moreChannels...)
+		cursor = nextCursor
+	}
+	return channels, nil
+}
+
+// ListChannels returns all public and private channels (including archived ones).
+func (w *Wiper) ListChannels(ctx context.Context) ([]slack.Channel, error) {
+	return w.listConversations(ctx, "private_channel", "public_channel")
+}
+
+// ListIMs returns all IMs and group IMs (including archived ones).
+func (w *Wiper) ListIMs(ctx context.Context) ([]slack.Channel, error) {
+	return w.listConversations(ctx, "mpim", "im")
+}
+
+// ResolveChannel returns the channel with the given name.
+func (w *Wiper) ResolveChannel(ctx context.Context, name string) (slack.Channel, error) {
+	channels, err := w.ListChannels(ctx)
+	if err != nil {
+		return slack.Channel{}, err
+	}
+	for _, c := range channels {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return slack.Channel{}, fmt.Errorf("channel not found: %q", name)
+}
+
+// ResolveChannels resolves channel names and glob patterns (or, if all is set, every channel the user is a member of).
+func (w *Wiper) ResolveChannels(ctx context.Context, patterns []string, all bool) ([]slack.Channel, error) {
+	channels, err := w.ListChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matched []slack.Channel
+	seen := make(map[string]bool)
+	if all {
+		for _, c := range channels {
+			if c.IsMember {
+				matched = append(matched, c)
+			}
+		}
+		return matched, nil
+	}
+	for _, p := range patterns {
+		n := 0
+		for _, c := range channels {
+			if ok, _ := path.Match(p, c.Name); !ok {
+				continue
+			}
+			n++
+			if !seen[c.ID] {
+				seen[c.ID] = true
+				matched = append(matched, c)
+			}
+		}
+		switch {
+		case n == 0 && IsGlob(p):
+			w.emit(Notice{Message: fmt.Sprintf("no channels match %q", p)})
+		case n == 0:
+			return nil, fmt.Errorf("channel not found: %q", p)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no channels match %q", strings.Join(patterns, ","))
+	}
+	return matched, nil
+}
+
+// IsGlob reports whether the channel name pattern contains glob syntax.
+func IsGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// ResolveIM returns the IM (for one other user) or group IM (for several) between the user and the given users.
+// The conversation's Members are filled in.
+func (w *Wiper) ResolveIM(ctx context.Context, userIDs []string) (slack.Channel, error) {
+	want := map[string]bool{w.userID: true}
+	for _, id := range userIDs {
+		want[id] = true
+	}
+	channels, err := w.ListIMs(ctx)
+	if err != nil {
+		return slack.Channel{}, err
+	}
+channels:
+	for _, c := range channels {
+		switch {
+		case c.IsIM && len(want) == 2 && want[c.User]:
+			c.Members = []string{w.userID, c.User}
+			return c, nil
+		case c.IsMpIM && len(want) > 2:
+			members, err := w.Members(ctx, c.ID)
+			if err != nil {
+				return slack.Channel{}, fmt.Errorf("fetch conversation members: %v", err)
+			}
+			if len(members) != len(want) {
+				continue
+			}
+			for _, m := range members {
+				if !want[m] {
+					continue channels
+				}
+			}
+			c.Members = members
+			return c, nil
+		}
+	}
+	return slack.Channel{}, fmt.Errorf("conversation not found")
+}
+
+// AllIMs returns every IM and group IM, except those with any of the given users.
+// The conversations are named after their members, and their Members are filled in.
+func (w *Wiper) AllIMs(ctx context.Context, keep map[string]bool) ([]slack.Channel, error) {
+	if _, err := w.Users(ctx); err != nil {
+		return nil, err
+	}
+	channels, err := w.ListIMs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ims []slack.Channel
+channels:
+	for _, c := range channels {
+		switch {
+		case c.IsIM:
+			if keep[c.User] {
+				continue
+			}
+			c.Name = fmt.Sprintf("IM with @%s", w.UserName(c.User))
+			c.Members = []string{w.userID, c.User}
+		case c.IsMpIM:
+			members, err := w.Members(ctx, c.ID)
+			if err != nil {
+				return nil, fmt.Errorf("fetch conversation members for %s: %v", c.ID, err)
+			}
+			var names []string
+			for _, m := range members {
+				if keep[m] {
+					continue channels
+				}
+				if m != w.userID {
+					names = append(names, "@"+w.UserName(m))
+				}
+			}
+			c.Name = fmt.Sprintf("group IM with %s", strings.Join(names, ", "))
+			c.Members = members
+		default:
+			continue
+		}
+		ims = append(ims, c)
+	}
+	return ims, nil
+}
+
+// Members returns the IDs of the conversation's members.
+func (w *Wiper) Members(ctx context.Context, channelID string) ([]string, error) {
+	params := &slack.GetUsersInConversationParameters{
+		ChannelID: channelID,
+	}
+	var users []string
+	for {
+		var moreUsers []string
+		var nextCursor string
+		err := w.call(ctx, "conversations.members", func() (err error) {
+			moreUsers, nextCursor, err = w.client.GetUsersInConversationContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, moreUsers...)
+		if nextCursor == "" {
+			break
+		}
+		params.Cursor = nextCursor
+	}
+	return users, nil
+}
+
+// Users returns all users of the workspace. The list is fetched once.
+func (w *Wiper) Users(ctx context.Context) ([]slack.User, error) {
+	w.mu.Lock()
+	users := w.users
+	w.mu.Unlock()
+	if users != nil {
+		return users, nil
+	}
+	err := w.call(ctx, "users.list", func() (err error) {
+		users, err = w.client.GetUsersContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	w.users = users
+	w.usersByID = make(map[string]slack.User, len(users))
+	for _, u := range users {
+		w.usersByID[u.ID] = u
+	}
+	w.mu.Unlock()
+	return users, nil
+}
+
+// UserByName returns the user with the given display name (or, failing that, user name), once Users was fetched.
+func (w *Wiper) UserByName(name string) (slack.User, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, u := range w.users {
+		if u.Profile.DisplayName == name {
+			return u, true
+		}
+	}
+	for _, u := range w.users {
+		if u.Name == name {
+			return u, true
+		}
+	}
+	return slack.User{}, false
+}
+
+// UserName returns the display name (or user name) of the user with the given ID, once Users was fetched.
+func (w *Wiper) UserName(id string) string {
+	w.mu.Lock()
+	u, ok := w.usersByID[id]
+	w.mu.Unlock()
+	switch {
+	case !ok:
+		return ""
+	case u.Profile.DisplayName != "":
+		return u.Profile.DisplayName
+	default:
+		return u.Name
+	}
+}
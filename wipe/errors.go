@@ -0,0 +1,96 @@
+package wipe
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+const maxAttempts = 5
+
+// goneErrors mean that the item is already gone, so there is nothing left to do.
+var goneErrors = map[string]bool{
+	"message_not_found": true,
+	"file_not_found":    true,
+	"file_deleted":      true,
+	"no_reaction":       true,
+	"not_pinned":        true,
+	"not_starred":       true,
+}
+
+// transientErrors are worth retrying.
+var transientErrors = map[string]bool{
+	"internal_error":      true,
+	"fatal_error":         true,
+	"request_timeout":     true,
+	"service_unavailable": true,
+	"ratelimited":         true,
+	"server_error":        true,
+	"network_error":       true,
+	"edit_not_confirmed":  true,
+}
+
+var errorCodePattern = regexp.MustCompile(`^[a-z_]+$`)
+
+// ErrorCode classifies an error by its Slack error code.
+func ErrorCode(err error) string {
+	switch err := err.(type) {
+	case nil:
+		return ""
+	case *PhaseError:
+		return ErrorCode(err.Err)
+	case *slack.RateLimitedError:
+		return "ratelimited"
+	case net.Error:
+		if err.Timeout() {
+			return "request_timeout"
+		}
+		return "network_error"
+	}
+	msg := err.Error()
+	switch {
+	case errorCodePattern.MatchString(msg):
+		return msg
+	case strings.HasPrefix(msg, "slack server error"):
+		return "server_error"
+	default:
+		return "other"
+	}
+}
+
+// attempt runs wipe, retrying transient errors with exponential backoff.
+// Errors meaning that the item is already gone are treated as success.
+// Once ctx is done, the last error is returned without further retries.
+// If wipe returns ctx's error, it did not make its request; if no request was made at all, attempts is zero.
+func attempt(ctx context.Context, wipe func() error) (attempts int, err error) {
+	backoff := time.Second
+	for attempts = 1; ; attempts++ {
+		lastErr := err
+		err = wipe()
+		if err != nil && err == ctx.Err() {
+			if attempts == 1 {
+				return 0, err
+			}
+			return attempts - 1, lastErr
+		}
+		code := ErrorCode(err)
+		switch {
+		case err == nil:
+			return attempts, nil
+		case goneErrors[code]:
+			return attempts, nil
+		case !transientErrors[code] || attempts == maxAttempts:
+			return attempts, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,45 @@
+package wipe
+
+import "time"
+
+// An Event reports progress. It is one of PageFetched, ItemWiped, RateLimited or Notice.
+type Event interface {
+	event()
+}
+
+// PageFetched is emitted after each page of a listing was fetched.
+type PageFetched struct {
+	Conversation string // empty for listings across all conversations
+	Kind         string
+	Fetched      int // pages fetched so far
+	Pages        int // 0 if unknown
+}
+
+// ItemWiped is emitted by Run for each item it attempted, in the order the items were received.
+type ItemWiped struct {
+	Item     Item
+	Attempts int
+	Err      error
+}
+
+// RateLimited is emitted when Slack responded with a rate limit error, and all calls pause.
+type RateLimited struct {
+	Method     string
+	RetryAfter time.Duration
+}
+
+// Notice is an informational message.
+type Notice struct {
+	Message string
+}
+
+func (PageFetched) event() {}
+func (ItemWiped) event()   {}
+func (RateLimited) event() {}
+func (Notice) event()      {}
+
+func (w *Wiper) emit(e Event) {
+	if w.events != nil {
+		w.events(e)
+	}
+}
@@ -0,0 +1,58 @@
+package wipe
+
+import (
+	"context"
+
+	"github.com/nlopes/slack"
+)
+
+// Files returns the user's files shared in c that were created in the date range.
+func (w *Wiper) Files(ctx context.Context, c slack.Channel) ([]slack.File, error) {
+	params := slack.NewGetFilesParameters()
+	params.Count = 200
+	params.User = w.userID
+	params.Channel = c.ID
+	if !w.after.IsZero() {
+		params.TimestampFrom = slack.JSONTime(w.after.Unix())
+	}
+	if !w.before.IsZero() {
+		params.TimestampTo = slack.JSONTime(w.before.Unix())
+	}
+	var files, filesPage []slack.File
+	var paging *slack.Paging
+	getFiles := func() (err error) {
+		filesPage, paging, err = w.client.GetFilesContext(ctx, params)
+		return err
+	}
+	pageMax := 1
+	for fetched := 1; params.Page <= pageMax; fetched++ {
+		if err := w.call(ctx, "files.list", getFiles); err != nil {
+			return nil, err
+		}
+		files = append(files, filesPage...)
+		if paging != nil {
+			pageMax = paging.Pages
+		}
+		params.Page++
+		w.emit(PageFetched{Conversation: c.ID, Kind: KindFile, Fetched: fetched, Pages: pageMax})
+	}
+	var own []slack.File
+	for _, f := range files {
+		if w.inDateRange(f.Created.Time()) {
+			own = append(own, f)
+		}
+	}
+	return own, nil
+}
+
+// FileInChannel reports whether the file is shared in the given conversation.
+func FileInChannel(f slack.File, channelID string) bool {
+	for _, ids := range [][]string{f.Channels, f.Groups, f.IMs} {
+		for _, id := range ids {
+			if id == channelID {
+				return true
+			}
+		}
+	}
+	return false
+}
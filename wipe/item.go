@@ -0,0 +1,107 @@
+package wipe
+
+import "github.com/nlopes/slack"
+
+// Item kinds.
+const (
+	KindMessage  = "message"
+	KindFile     = "file"
+	KindReaction = "reaction"
+	KindPin      = "pin"
+	KindStar     = "star"
+)
+
+// Item actions.
+const (
+	ActionDelete = "delete"
+	ActionRedact = "redact"
+	// ActionOverwrite redacts a message, confirms the edit, and then deletes the message.
+	ActionOverwrite = "overwrite"
+	ActionRemove    = "remove"
+)
+
+// A Step is one kind of item with one action.
+type Step struct {
+	Kind   string
+	Action string
+}
+
+// Steps are the supported steps, in the order they are wiped.
+// Pins and stars go first, so they do not dangle once their messages and files are gone.
+var Steps = []Step{
+	{KindPin, ActionRemove},
+	{KindStar, ActionRemove},
+	{KindReaction, ActionRemove},
+	{KindMessage, ActionDelete},
+	{KindMessage, ActionRedact},
+	{KindMessage, ActionOverwrite},
+	{KindFile, ActionDelete},
+}
+
+// An Item is a single thing to wipe.
+type Item struct {
+	Kind      string
+	Action    string
+	Channel   string `json:",omitempty"`
+	Timestamp string `json:",omitempty"`
+	File      string `json:",omitempty"`
+	Name      string `json:",omitempty"`
+	Redacted  string `json:",omitempty"`
+	Reaction  string `json:",omitempty"`
+
+	// Attachments replace the message's attachments when it is redacted or overwritten.
+	Attachments []slack.Attachment `json:",omitempty"`
+}
+
+// Step returns the item's step.
+func (item Item) Step() Step { return Step{item.Kind, item.Action} }
+
+// Key identifies the item.
+func (item Item) Key() string {
+	return item.Kind + "/" + item.Action + "/" + item.Channel + "/" + item.Timestamp + item.File + "/" + item.Reaction
+}
+
+func (item Item) ref() slack.ItemRef {
+	if item.File != "" {
+		return slack.NewRefToFile(item.File)
+	}
+	return slack.NewRefToMessage(item.Channel, item.Timestamp)
+}
+
+// MessageItems returns the items that delete (or redact, or overwrite) the given messages in c.
+func (w *Wiper) MessageItems(c slack.Channel, messages []slack.SearchMessage) []Item {
+	items := make([]Item, 0, len(messages))
+	for _, m := range messages {
+		item := Item{
+			Kind:      KindMessage,
+			Action:    ActionDelete,
+			Channel:   c.ID,
+			Timestamp: m.Timestamp,
+		}
+		if w.redact != nil {
+			item.Action = ActionRedact
+			if w.overwriting {
+				item.Action = ActionOverwrite
+			}
+			item.Redacted = w.redact.Redact(m.Text)
+			item.Attachments = w.redactAttachments(m.Attachments)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// FileItems returns the items that delete the given files (listed for c).
+func FileItems(c slack.Channel, files []slack.File) []Item {
+	items := make([]Item, 0, len(files))
+	for _, f := range files {
+		items = append(items, Item{
+			Kind:    KindFile,
+			Action:  ActionDelete,
+			Channel: c.ID,
+			File:    f.ID,
+			Name:    f.Name,
+		})
+	}
+	return items
+}
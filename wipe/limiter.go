@@ -0,0 +1,120 @@
+package wipe
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Slack's rate limit tiers, in requests per minute (https://api.slack.com/docs/rate-limits).
+var rateLimitTiers = map[int]int{
+	1: 1,
+	2: 20,
+	3: 50,
+	4: 100,
+}
+
+// methodTiers assigns each Web API method we call to its rate limit tier.
+// Methods missing from this table are throttled as tier 2.
+var methodTiers = map[string]int{
+	"auth.test":             3,
+	"users.list":            2,
+	"conversations.list":    2,
+	"conversations.members": 4,
+	"conversations.history": 2,
+	"conversations.replies": 3,
+	"search.messages":       2,
+	"files.list":            3,
+	"files.delete":          3,
+	"chat.delete":           3,
+	"chat.update":           3,
+	"reactions.list":        2,
+	"reactions.remove":      3,
+	"pins.list":             2,
+	"pins.remove":           2,
+	"stars.list":            3,
+	"stars.remove":          2,
+}
+
+const maxRateLimitRetries = 10
+
+// rateLimiter throttles calls per tier, and pauses all calls when Slack responds with a rate limit error.
+type rateLimiter struct {
+	ticks  map[int]<-chan time.Time
+	notify func(Event)
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+func newRateLimiter(notify func(Event)) *rateLimiter {
+	l := &rateLimiter{
+		ticks:  make(map[int]<-chan time.Time, len(rateLimitTiers)),
+		notify: notify,
+	}
+	for tier, perMinute := range rateLimitTiers {
+		l.ticks[tier] = time.Tick(time.Minute / time.Duration(perMinute))
+	}
+	return l
+}
+
+// wait waits for the method's rate limit and for any pause, or until ctx is done.
+func (l *rateLimiter) wait(ctx context.Context, method string) error {
+	tier, ok := methodTiers[method]
+	if !ok {
+		tier = 2
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.ticks[tier]:
+	}
+	for {
+		l.mu.Lock()
+		d := time.Until(l.pausedUntil)
+		l.mu.Unlock()
+		if d <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// call runs f after waiting for the method's rate limit, and retries it after Slack's Retry-After delay if it was rate limited.
+// Once ctx is done, f is not run (again), and ctx's error is returned.
+func (l *rateLimiter) call(ctx context.Context, method string, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(ctx, method); err != nil {
+			return err
+		}
+		err := f()
+		rateLimited, ok := err.(*slack.RateLimitedError)
+		if !ok || attempt == maxRateLimitRetries {
+			return err
+		}
+		retryAfter := rateLimited.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		l.notify(RateLimited{Method: method, RetryAfter: retryAfter})
+		l.pause(retryAfter)
+	}
+}
+
+func (w *Wiper) call(ctx context.Context, method string, f func() error) error {
+	return w.limiter.call(ctx, method, f)
+}
@@ -0,0 +1,316 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+const searchDateFormat = "2006-01-02"
+
+// Messages returns the user's messages in c that pass the date range and content filters.
+func (w *Wiper) Messages(ctx context.Context, c slack.Channel) ([]slack.SearchMessage, error) {
+	var own, messages []slack.SearchMessage
+	err := w.ownMessagePages(ctx, c, func(page []slack.SearchMessage) error {
+		own = append(own, page...)
+		messages = append(messages, w.FilterMessages(page)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if w.match != nil || w.exclude != nil {
+		w.emit(Notice{Message: fmt.Sprintf("%d of %d messages pass the content filters", len(messages), len(own))})
+	}
+	return messages, nil
+}
+
+// MessagePages calls emit with the user's messages in c that pass the date range and content filters, one page at a time.
+// Channels are searched; IMs and group IMs are read from their history, including thread replies.
+// Search result pages are walked from last to first, so that wiping the messages of one page does not shift the pages still to come.
+func (w *Wiper) MessagePages(ctx context.Context, c slack.Channel, emit func([]slack.SearchMessage) error) error {
+	return w.ownMessagePages(ctx, c, func(page []slack.SearchMessage) error {
+		return emit(w.FilterMessages(page))
+	})
+}
+
+// MessageTotal returns the number of search results for the user's messages in the channel c, before filtering.
+func (w *Wiper) MessageTotal(ctx context.Context, c slack.Channel) (int, error) {
+	params := slack.NewSearchParameters()
+	params.Count = 1
+	var resp *slack.SearchMessages
+	err := w.call(ctx, "search.messages", func() (err error) {
+		resp, err = w.client.SearchMessagesContext(ctx, w.messageSearchQuery(c), params)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Total, nil
+}
+
+func (w *Wiper) ownMessagePages(ctx context.Context, c slack.Channel, emit func([]slack.SearchMessage) error) error {
+	if c.IsIM || c.IsMpIM {
+		return w.historyPages(ctx, c, emit)
+	}
+	return w.searchPages(ctx, c, emit)
+}
+
+func (w *Wiper) messageSearchQuery(c slack.Channel) string {
+	query := fmt.Sprintf("in:#%s from:@%s", c.Name, w.userID)
+	if modifiers := w.searchDateModifiers(); modifiers != "" {
+		query += " " + modifiers
+	}
+	return query
+}
+
+func (w *Wiper) searchPages(ctx context.Context, c slack.Channel, emit func([]slack.SearchMessage) error) error {
+	params := slack.NewSearchParameters()
+	params.Count = 100
+	query := w.messageSearchQuery(c)
+	var resp *slack.SearchMessages
+	search := func() (err error) {
+		resp, err = w.client.SearchMessagesContext(ctx, query, params)
+		return err
+	}
+	if err := w.call(ctx, "search.messages", search); err != nil {
+		return err
+	}
+	first := resp.Matches
+	pages := resp.PageCount
+	if pages < 1 {
+		pages = 1
+	}
+	fetched := 1
+	w.emit(PageFetched{Conversation: c.ID, Kind: KindMessage, Fetched: fetched, Pages: pages})
+	for params.Page = pages; params.Page > 1; params.Page-- {
+		if err := w.call(ctx, "search.messages", search); err != nil {
+			return err
+		}
+		fetched++
+		w.emit(PageFetched{Conversation: c.ID, Kind: KindMessage, Fetched: fetched, Pages: pages})
+		if err := emit(w.ownSearchMessages(resp.Matches)); err != nil {
+			return err
+		}
+	}
+	return emit(w.ownSearchMessages(first))
+}
+
+func (w *Wiper) ownSearchMessages(messages []slack.SearchMessage) []slack.SearchMessage {
+	var own []slack.SearchMessage
+	for _, m := range messages {
+		if m.User == w.userID && w.inDateRange(TimestampTime(m.Timestamp)) {
+			own = append(own, m)
+		}
+	}
+	return own
+}
+
+func (w *Wiper) historyPages(ctx context.Context, c slack.Channel, emit func([]slack.SearchMessage) error) error {
+	params := &slack.GetConversationHistoryParameters{
+		ChannelID: c.ID,
+	}
+	// History is not bounded by w.after: it lists thread parents only, and a parent posted
+	// before w.after may have replies after it. Parents posted after w.before cannot.
+	// w.oldest bounds it anyway, at the cost of those replies.
+	if !w.before.IsZero() {
+		params.Latest = slackTimestamp(w.before)
+	}
+	if !w.oldest.IsZero() {
+		params.Oldest = slackTimestamp(w.oldest)
+	}
+	var hist *slack.GetConversationHistoryResponse
+	getHistory := func() (err error) {
+		hist, err = w.client.GetConversationHistoryContext(ctx, params)
+		return err
+	}
+	if err := w.call(ctx, "conversations.history", getHistory); err != nil {
+		return err
+	}
+	// A reply that was also sent to the conversation (a thread_broadcast) is both in the history and in its thread.
+	seen := make(map[string]bool)
+	add := func(own []slack.SearchMessage, m slack.SearchMessage) []slack.SearchMessage {
+		if seen[m.Timestamp] {
+			return own
+		}
+		seen[m.Timestamp] = true
+		return append(own, m)
+	}
+	for fetched := 1; ; fetched++ {
+		w.emit(PageFetched{Conversation: c.ID, Kind: KindMessage, Fetched: fetched})
+		var own []slack.SearchMessage
+		for _, m := range hist.Messages {
+			if m.User == w.userID && w.inDateRange(TimestampTime(m.Timestamp)) {
+				own = add(own, w.directMessage(c, m))
+			}
+			if m.ReplyCount > 0 || (m.ThreadTimestamp != "" && m.ThreadTimestamp == m.Timestamp) {
+				replies, err := w.threadReplies(ctx, c, m.Timestamp)
+				if err != nil {
+					return fmt.Errorf("fetch replies to %s: %v", m.Timestamp, err)
+				}
+				for _, reply := range replies {
+					own = add(own, reply)
+				}
+			}
+		}
+		if err := emit(own); err != nil {
+			return err
+		}
+		nextCursor := hist.ResponseMetaData.NextCursor
+		if nextCursor == "" || !hist.HasMore {
+			return nil
+		}
+		params.Cursor = nextCursor
+		if err := w.call(ctx, "conversations.history", getHistory); err != nil {
+			return err
+		}
+	}
+}
+
+// threadReplies returns the user's replies in the thread started by threadTimestamp (without the parent).
+func (w *Wiper) threadReplies(ctx context.Context, c slack.Channel, threadTimestamp string) ([]slack.SearchMessage, error) {
+	params := &slack.GetConversationRepliesParameters{
+		ChannelID: c.ID,
+		Timestamp: threadTimestamp,
+		Limit:     200,
+	}
+	var own []slack.SearchMessage
+	for {
+		var msgs []slack.Message
+		var hasMore bool
+		var nextCursor string
+		err := w.call(ctx, "conversations.replies", func() (err error) {
+			msgs, hasMore, nextCursor, err = w.client.GetConversationRepliesContext(ctx, params)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range msgs {
+			if m.Timestamp == threadTimestamp {
+				continue
+			}
+			if m.User == w.userID && w.inDateRange(TimestampTime(m.Timestamp)) {
+				own = append(own, w.directMessage(c, m))
+			}
+		}
+		if nextCursor == "" || !hasMore {
+			break
+		}
+		params.Cursor = nextCursor
+	}
+	return own, nil
+}
+
+// directMessage converts a message read from the history of c. Unlike search results, history has no permalinks,
+// so they are built from the workspace URL.
+func (w *Wiper) directMessage(c slack.Channel, m slack.Message) slack.SearchMessage {
+	return slack.SearchMessage{
+		Type:        m.Type,
+		Channel:     slack.CtxChannel{ID: c.ID, Name: c.Name},
+		User:        m.User,
+		Username:    m.Username,
+		Timestamp:   m.Timestamp,
+		Text:        m.Text,
+		Attachments: m.Attachments,
+		Permalink:   w.permalink(c.ID, m.Timestamp, m.ThreadTimestamp),
+	}
+}
+
+// permalink returns the link to a message, in the format of chat.getPermalink.
+func (w *Wiper) permalink(channel, ts, threadTS string) string {
+	if w.teamURL == "" {
+		return ""
+	}
+	link := strings.TrimSuffix(w.teamURL, "/") + "/archives/" + channel + "/p" + strings.Replace(ts, ".", "", 1)
+	if threadTS != "" && threadTS != ts {
+		link += "?thread_ts=" + threadTS + "&cid=" + channel
+	}
+	return link
+}
+
+// inDateRange reports whether t lies in [after, before).
+func (w *Wiper) inDateRange(t time.Time) bool {
+	if !w.after.IsZero() && t.Before(w.after) {
+		return false
+	}
+	if !w.before.IsZero() && !t.Before(w.before) {
+		return false
+	}
+	return true
+}
+
+// searchDateModifiers returns after:/before: search modifiers covering the date range.
+// Both modifiers are exclusive and only have day granularity, so they are widened by a day
+// and the results are filtered precisely with inDateRange.
+func (w *Wiper) searchDateModifiers() string {
+	var modifiers []string
+	if !w.after.IsZero() {
+		modifiers = append(modifiers, "after:"+w.after.AddDate(0, 0, -1).Format(searchDateFormat))
+	}
+	if !w.before.IsZero() {
+		modifiers = append(modifiers, "before:"+w.before.AddDate(0, 0, 1).Format(searchDateFormat))
+	}
+	return strings.Join(modifiers, " ")
+}
+
+func slackTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+// TimestampTime converts a Slack message timestamp to a time.
+func TimestampTime(ts string) time.Time {
+	parts := strings.SplitN(ts, ".", 2)
+	sec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	var usec int64
+	if len(parts) == 2 {
+		usec, _ = strconv.ParseInt(parts[1], 10, 64)
+	}
+	return time.Unix(sec, usec*int64(time.Microsecond)).UTC()
+}
+
+// FilterMessages keeps the messages whose text (or attachment text) matches the match pattern, and does not match the exclude pattern.
+func (w *Wiper) FilterMessages(messages []slack.SearchMessage) []slack.SearchMessage {
+	if w.match == nil && w.exclude == nil {
+		return messages
+	}
+	var filtered []slack.SearchMessage
+	for _, m := range messages {
+		texts := messageTexts(m)
+		if w.match != nil && !anyMatch(w.match.MatchString, texts) {
+			continue
+		}
+		if w.exclude != nil && anyMatch(w.exclude.MatchString, texts) {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+func messageTexts(m slack.SearchMessage) []string {
+	texts := []string{m.Text}
+	for _, a := range m.Attachments {
+		texts = append(texts, a.Fallback, a.Pretext, a.Title, a.TitleLink, a.Text, a.Footer)
+		for _, f := range a.Fields {
+			texts = append(texts, f.Title, f.Value)
+		}
+	}
+	return texts
+}
+
+func anyMatch(match func(string) bool, texts []string) bool {
+	for _, t := range texts {
+		if t != "" && match(t) {
+			return true
+		}
+	}
+	return false
+}
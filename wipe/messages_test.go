@@ -0,0 +1,74 @@
+package wipe
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// threadWorkspace adds, in the IM, an old thread by the other user with a recent reply by the test user,
+// and a recent message by the test user.
+func threadWorkspace(t *testing.T) (now time.Time, replies func() int) {
+	s := newWorkspace(t)
+	now = time.Now()
+	parent := s.Post("D000000BOB", otherUser, "old thread", now.Add(-48*time.Hour), "")
+	s.Post("D000000BOB", testUser, "recent reply", now.Add(-10*time.Minute), parent)
+	s.Post("D000000BOB", testUser, "recent message", now.Add(-5*time.Minute), "")
+	return now, func() int { return s.Calls("conversations.replies") }
+}
+
+func imMessages(t *testing.T, options ...Option) []string {
+	t.Helper()
+	ctx := context.Background()
+	w, err := New(ctx, append([]Option{WithToken(testToken)}, options...)...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c, err := w.ResolveIM(ctx, []string{otherUser})
+	if err != nil {
+		t.Fatalf("ResolveIM: %v", err)
+	}
+	messages, err := w.Messages(ctx, c)
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+	var texts []string
+	for _, m := range messages {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+func TestHistoryAfterFindsRepliesInOlderThreads(t *testing.T) {
+	now, replies := threadWorkspace(t)
+	got := imMessages(t, WithDateRange(now.Add(-30*time.Minute), time.Time{}))
+	if len(got) != 2 {
+		t.Errorf("messages = %q, want the recent reply and message", got)
+	}
+	if replies() == 0 {
+		t.Error("conversations.replies was not called for the older thread")
+	}
+}
+
+func TestWithOldestBoundsHistory(t *testing.T) {
+	now, replies := threadWorkspace(t)
+	since := now.Add(-30 * time.Minute)
+	got := imMessages(t, WithDateRange(since, time.Time{}), WithOldest(since))
+	if len(got) != 1 || got[0] != "recent message" {
+		t.Errorf("messages = %q, want only the recent message", got)
+	}
+	if n := replies(); n != 0 {
+		t.Errorf("conversations.replies was called %d times, want 0", n)
+	}
+}
+
+func TestHistoryListsBroadcastRepliesOnce(t *testing.T) {
+	s := newWorkspace(t)
+	now := time.Now()
+	parent := s.Post("D000000BOB", otherUser, "thread", now.Add(-10*time.Minute), "")
+	s.Broadcast("D000000BOB", testUser, "broadcast reply", now.Add(-5*time.Minute), parent)
+	got := imMessages(t, WithDateRange(now.Add(-30*time.Minute), time.Time{}))
+	if len(got) != 1 || got[0] != "broadcast reply" {
+		t.Errorf("messages = %q, want the broadcast reply once", got)
+	}
+}
@@ -0,0 +1,227 @@
+package wipe
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A TokenType is the kind of a piece of Slack markup (mrkdwn).
+type TokenType int
+
+// Token types.
+const (
+	TokenText TokenType = iota
+	TokenUserMention
+	TokenChannelMention
+	TokenURL
+	TokenEmoji
+	TokenCode
+	TokenCodeBlock
+)
+
+var tokenTypeNames = map[TokenType]string{
+	TokenText:           "text",
+	TokenUserMention:    "user",
+	TokenChannelMention: "channel",
+	TokenURL:            "url",
+	TokenEmoji:          "emoji",
+	TokenCode:           "code",
+	TokenCodeBlock:      "codeblock",
+}
+
+func (t TokenType) String() string { return tokenTypeNames[t] }
+
+// A Token is a piece of Slack markup. The texts of the tokens of a message add up to the message text.
+type Token struct {
+	Type TokenType
+	Text string
+}
+
+var emojiPattern = regexp.MustCompile(`^:[a-z0-9_+'-]+:(?::skin-tone-[2-6]:)?`)
+
+// Tokenize splits Slack markup into mentions (<@U…>, <!here>), channel mentions (<#C…>), links (<https://…|label>),
+// :emoji: codes, `inline code`, ```code blocks``` and the plain text between them.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := 0
+	for i := 0; i < len(text); {
+		t, n := markupAt(text, i)
+		if n == 0 {
+			_, size := utf8.DecodeRuneInString(text[i:])
+			i += size
+			continue
+		}
+		if start < i {
+			tokens = append(tokens, Token{TokenText, text[start:i]})
+		}
+		tokens = append(tokens, Token{t, text[i : i+n]})
+		i += n
+		start = i
+	}
+	if start < len(text) {
+		tokens = append(tokens, Token{TokenText, text[start:]})
+	}
+	return tokens
+}
+
+// markupAt returns the type and length of the markup token starting at text[i], or zero if there is none.
+func markupAt(text string, i int) (TokenType, int) {
+	rest := text[i:]
+	switch rest[0] {
+	case '`':
+		if strings.HasPrefix(rest, "```") {
+			if end := strings.Index(rest[3:], "```"); end >= 0 {
+				return TokenCodeBlock, 3 + end + 3
+			}
+			return 0, 0
+		}
+		if end := strings.IndexAny(rest[1:], "`\n"); end > 0 && rest[1+end] == '`' {
+			return TokenCode, 1 + end + 1
+		}
+	case '<':
+		end := strings.IndexAny(rest[1:], "<>\n")
+		if end <= 0 || rest[1+end] != '>' {
+			return 0, 0
+		}
+		switch rest[1] {
+		case '@', '!':
+			return TokenUserMention, 1 + end + 1
+		case '#':
+			return TokenChannelMention, 1 + end + 1
+		}
+		if strings.Contains(rest[1:1+end], ":") {
+			return TokenURL, 1 + end + 1
+		}
+	case ':':
+		if i > 0 {
+			r, _ := utf8.DecodeLastRuneInString(text[:i])
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return 0, 0
+			}
+		}
+		if m := emojiPattern.FindString(rest); m != "" {
+			return TokenEmoji, len(m)
+		}
+	}
+	return 0, 0
+}
+
+// A Treatment says what redaction does with a token.
+type Treatment int
+
+// Treatments.
+const (
+	TreatMask Treatment = iota
+	TreatKeep
+	TreatRemove
+)
+
+var treatmentNames = map[string]Treatment{
+	"mask":   TreatMask,
+	"keep":   TreatKeep,
+	"remove": TreatRemove,
+}
+
+// A MarkupPolicy gives the treatment of each token type. Token types that are not listed are masked.
+type MarkupPolicy map[TokenType]Treatment
+
+// ParseMarkupPolicy parses a comma-separated list of TYPE=TREATMENT pairs, such as "user=keep,url=remove".
+// Types are text, user, channel, url, emoji, code and codeblock; treatments are keep, mask and remove.
+func ParseMarkupPolicy(s string) (MarkupPolicy, error) {
+	types := make(map[string]TokenType, len(tokenTypeNames))
+	var typeNames []string
+	for t, name := range tokenTypeNames {
+		types[name] = t
+		typeNames = append(typeNames, name)
+	}
+	sort.Strings(typeNames)
+	policy := MarkupPolicy{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q: expected TYPE=TREATMENT", pair)
+		}
+		t, ok := types[strings.TrimSpace(parts[0])]
+		if !ok {
+			return nil, fmt.Errorf("%q: unknown token type (expected one of %s)", pair, strings.Join(typeNames, ", "))
+		}
+		treatment, ok := treatmentNames[strings.TrimSpace(parts[1])]
+		if !ok {
+			return nil, fmt.Errorf("%q: unknown treatment (expected keep, mask or remove)", pair)
+		}
+		policy[t] = treatment
+	}
+	return policy, nil
+}
+
+// MarkupRedactor redacts Slack markup token by token, as the policy says.
+// Masked tokens are redacted by inner: text and code keep their delimiters, while masked mentions, links
+// and emoji become plain text. The result is always valid markup.
+func MarkupRedactor(inner Redactor, policy MarkupPolicy) Redactor {
+	return RedactorFunc(func(text string) string {
+		var b strings.Builder
+		for _, t := range Tokenize(text) {
+			switch policy[t.Type] {
+			case TreatKeep:
+				b.WriteString(t.Text)
+			case TreatMask:
+				b.WriteString(maskToken(inner, t))
+			}
+		}
+		return b.String()
+	})
+}
+
+func maskToken(inner Redactor, t Token) string {
+	redact := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return escapeMarkup(inner.Redact(unescapeMarkup(s)))
+	}
+	switch t.Type {
+	case TokenUserMention:
+		return "@" + redact(strings.TrimLeft(linkLabel(t.Text), "@!"))
+	case TokenChannelMention:
+		return "#" + redact(strings.TrimLeft(linkLabel(t.Text), "#"))
+	case TokenURL:
+		return redact(linkLabel(t.Text))
+	case TokenEmoji:
+		return ":" + strings.Replace(redact(strings.Trim(t.Text, ":")), ":", "", -1) + ":"
+	case TokenCode:
+		code := strings.Replace(redact(t.Text[1:len(t.Text)-1]), "`", "", -1)
+		if strings.TrimSpace(code) == "" {
+			return ""
+		}
+		return "`" + code + "`"
+	case TokenCodeBlock:
+		code := strings.Replace(redact(t.Text[3:len(t.Text)-3]), "`", "", -1)
+		if strings.TrimSpace(code) == "" {
+			return ""
+		}
+		return "```" + code + "```"
+	}
+	return redact(t.Text)
+}
+
+// linkLabel returns the label of <target|label>, or the target if there is no label.
+func linkLabel(link string) string {
+	link = link[1 : len(link)-1]
+	if i := strings.Index(link, "|"); i >= 0 {
+		return link[i+1:]
+	}
+	return link
+}
+
+var (
+	unescapeMarkup = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace
+	escapeMarkup   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace
+)
@@ -0,0 +1,109 @@
+package wipe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Token
+	}{
+		{"", nil},
+		{"plain text", []Token{{TokenText, "plain text"}}},
+		{"hi <@U123|bob> and <!here>", []Token{
+			{TokenText, "hi "}, {TokenUserMention, "<@U123|bob>"}, {TokenText, " and "}, {TokenUserMention, "<!here>"},
+		}},
+		{"see <#C123|general>", []Token{{TokenText, "see "}, {TokenChannelMention, "<#C123|general>"}}},
+		{"<https://example.com|site> <mailto:a@b.c>", []Token{
+			{TokenURL, "<https://example.com|site>"}, {TokenText, " "}, {TokenURL, "<mailto:a@b.c>"},
+		}},
+		{":tada: done :+1::skin-tone-3:", []Token{
+			{TokenEmoji, ":tada:"}, {TokenText, " done "}, {TokenEmoji, ":+1::skin-tone-3:"},
+		}},
+		{"at 10:30:00 ok", []Token{{TokenText, "at 10:30:00 ok"}}},
+		{"run `make` then ```\ngo test\n```", []Token{
+			{TokenText, "run "}, {TokenCode, "`make`"}, {TokenText, " then "}, {TokenCodeBlock, "```\ngo test\n```"},
+		}},
+		{"a `b\nc` d", []Token{{TokenText, "a `b\nc` d"}}},
+		{"```unclosed", []Token{{TokenText, "```unclosed"}}},
+		{"&lt;not a link&gt; &amp; <b", []Token{{TokenText, "&lt;not a link&gt; &amp; <b"}}},
+	}
+	for _, test := range tests {
+		if got := Tokenize(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestMarkupRedactor(t *testing.T) {
+	tests := []struct {
+		policy string
+		text   string
+		want   string
+	}{
+		{"", "hi <@U123|bob>", "██ @███"},
+		{"", "ping <@U123> <!here>", "████ @████ @████"},
+		{"", "in <#C123|general>", "██ #███████"},
+		{"", "<https://example.com|the site>", "███ ████"},
+		{"", "<https://x.io>", "█████://█.██"},
+		{"", "yay :tada:", "███ :████:"},
+		{"", "run `make all` now", "███ `████ ███` ███"},
+		{"", "```go test```", "```██ ████```"},
+		{"", "a &amp; b &lt;3", "█ &amp; █ ██"},
+		{"user=keep,url=remove", "hi <@U1|bob> see <https://x|y>!", "██ <@U1|bob> ███ !"},
+		{"text=keep,emoji=keep,code=remove", "ok :tada: `secret`", "ok :tada: "},
+		{"channel=keep,codeblock=keep", "<#C1|random> ```x```", "<#C1|random> ```x```"},
+	}
+	for _, test := range tests {
+		policy, err := ParseMarkupPolicy(test.policy)
+		if err != nil {
+			t.Fatalf("ParseMarkupPolicy(%q): %v", test.policy, err)
+		}
+		r := MarkupRedactor(ShapeRedactor('█'), policy)
+		if got := r.Redact(test.text); got != test.want {
+			t.Errorf("policy %q: Redact(%q) = %q, want %q", test.policy, test.text, got, test.want)
+		}
+	}
+}
+
+// TestMarkupRedactorValid checks that masked tokens cannot turn into mentions or links, whatever the inner redactor returns.
+func TestMarkupRedactorValid(t *testing.T) {
+	inner := FixedRedactor("<@U999> <#C999> <https://evil.example> & `x`")
+	r := MarkupRedactor(inner, MarkupPolicy{})
+	for _, text := range []string{
+		"hello",
+		"<@U123|bob>",
+		"<#C123|general>",
+		"<https://example.com|site>",
+		":tada:",
+		"`code`",
+		"```block```",
+		"&lt;b&gt;",
+	} {
+		got := r.Redact(text)
+		for _, token := range Tokenize(got) {
+			switch token.Type {
+			case TokenUserMention, TokenChannelMention, TokenURL:
+				t.Errorf("Redact(%q) = %q contains %s markup %q", text, got, token.Type, token.Text)
+			}
+		}
+	}
+}
+
+func TestParseMarkupPolicy(t *testing.T) {
+	policy, err := ParseMarkupPolicy(" user=keep, url=remove ,")
+	if err != nil {
+		t.Fatalf("ParseMarkupPolicy: %v", err)
+	}
+	want := MarkupPolicy{TokenUserMention: TreatKeep, TokenURL: TreatRemove}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("ParseMarkupPolicy = %v, want %v", policy, want)
+	}
+	for _, s := range []string{"user", "link=keep", "user=hide"} {
+		if _, err := ParseMarkupPolicy(s); err == nil {
+			t.Errorf("ParseMarkupPolicy(%q): no error", s)
+		}
+	}
+}
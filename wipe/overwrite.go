@@ -0,0 +1,112 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// Phases of an overwrite, in order.
+const (
+	PhaseOverwrite = "overwrite"
+	PhaseConfirm   = "confirm"
+	PhaseDelete    = "delete"
+)
+
+var overwritePhases = []string{PhaseOverwrite, PhaseConfirm, PhaseDelete}
+
+// A PhaseError is the failure of one phase of an overwrite.
+// If the phase is PhaseDelete, the message was overwritten but is still there.
+type PhaseError struct {
+	Phase string
+	Err   error
+}
+
+func (e *PhaseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Phase, e.Err)
+}
+
+// Phase returns the overwrite phase that failed, or the empty string.
+func Phase(err error) string {
+	if err, ok := err.(*PhaseError); ok {
+		return err.Phase
+	}
+	return ""
+}
+
+// errNotConfirmed means that the message does not show the overwritten text (yet).
+var errNotConfirmed = errors.New("edit_not_confirmed")
+
+// overwrite runs the phases of an overwrite, retrying each one separately, so that a failed deletion does not repeat the edit.
+func (w *Wiper) overwrite(ctx context.Context, item Item) (int, error) {
+	attempts := 0
+	for _, phase := range overwritePhases {
+		n, err := attempt(ctx, func() error { return w.overwritePhase(ctx, context.Background(), item, phase) })
+		attempts += n
+		if attempts == 0 {
+			return 0, err
+		}
+		if err != nil {
+			return attempts, &PhaseError{Phase: phase, Err: err}
+		}
+	}
+	return attempts, nil
+}
+
+// overwritePhase executes a single phase of an overwrite (once, without retries),
+// waiting for the rate limit until waitCtx is done and making the request with ctx.
+func (w *Wiper) overwritePhase(waitCtx, ctx context.Context, item Item, phase string) error {
+	switch phase {
+	case PhaseOverwrite:
+		return w.call(waitCtx, "chat.update", func() error {
+			ctx, cancel := context.WithTimeout(ctx, CallTimeout)
+			defer cancel()
+			return w.update(ctx, item)
+		})
+	case PhaseConfirm:
+		return w.call(waitCtx, "conversations.replies", func() error {
+			ctx, cancel := context.WithTimeout(ctx, CallTimeout)
+			defer cancel()
+			return w.confirmUpdate(ctx, item)
+		})
+	case PhaseDelete:
+		return w.call(waitCtx, "chat.delete", func() error {
+			ctx, cancel := context.WithTimeout(ctx, CallTimeout)
+			defer cancel()
+			_, _, err := w.client.DeleteMessageContext(ctx, item.Channel, item.Timestamp)
+			return err
+		})
+	}
+	return fmt.Errorf("unknown overwrite phase %q", phase)
+}
+
+// confirmUpdate reads the message back and checks that it shows the redacted text.
+func (w *Wiper) confirmUpdate(ctx context.Context, item Item) error {
+	params := &slack.GetConversationRepliesParameters{
+		ChannelID: item.Channel,
+		Timestamp: item.Timestamp,
+		Oldest:    item.Timestamp,
+		Latest:    item.Timestamp,
+		Inclusive: true,
+	}
+	msgs, _, _, err := w.client.GetConversationRepliesContext(ctx, params)
+	if err != nil {
+		if ErrorCode(err) == "thread_not_found" {
+			return errors.New("message_not_found")
+		}
+		return err
+	}
+	for _, m := range msgs {
+		if m.Timestamp != item.Timestamp {
+			continue
+		}
+		if strings.TrimSpace(m.Text) != strings.TrimSpace(item.Redacted) {
+			return errNotConfirmed
+		}
+		return nil
+	}
+	return errNotConfirmed
+}
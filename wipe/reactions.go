@@ -0,0 +1,181 @@
+package wipe
+
+import (
+	"context"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Reactions returns all items the user reacted to (in any conversation). The list is fetched once.
+func (w *Wiper) Reactions(ctx context.Context) ([]slack.ReactedItem, error) {
+	w.mu.Lock()
+	reacted := w.reactions
+	w.mu.Unlock()
+	if reacted != nil {
+		return reacted, nil
+	}
+	params := slack.NewListReactionsParameters()
+	params.User = w.userID
+	params.Count = 100
+	params.Full = true
+	reacted = []slack.ReactedItem{}
+	var reactedPage []slack.ReactedItem
+	var paging *slack.Paging
+	listReactions := func() (err error) {
+		reactedPage, paging, err = w.client.ListReactionsContext(ctx, params)
+		return err
+	}
+	pageMax := 1
+	for fetched := 1; params.Page <= pageMax; fetched++ {
+		if err := w.call(ctx, "reactions.list", listReactions); err != nil {
+			return nil, err
+		}
+		reacted = append(reacted, reactedPage...)
+		if paging != nil {
+			pageMax = paging.Pages
+		}
+		params.Page++
+		w.emit(PageFetched{Kind: KindReaction, Fetched: fetched, Pages: pageMax})
+	}
+	w.mu.Lock()
+	w.reactions = reacted
+	w.mu.Unlock()
+	return reacted, nil
+}
+
+// ReactionItems returns the items that remove the user's reactions on messages and files in c posted in the date range.
+func (w *Wiper) ReactionItems(c slack.Channel, reacted []slack.ReactedItem) []Item {
+	var items []Item
+	for _, r := range reacted {
+		item := Item{
+			Kind:    KindReaction,
+			Action:  ActionRemove,
+			Channel: c.ID,
+		}
+		var posted time.Time
+		switch {
+		case r.Type == slack.TYPE_MESSAGE && r.Message != nil && r.Channel == c.ID:
+			item.Timestamp = r.Message.Timestamp
+			posted = TimestampTime(item.Timestamp)
+		case r.Type == slack.TYPE_FILE && r.File != nil && FileInChannel(*r.File, c.ID):
+			item.File = r.File.ID
+			posted = r.File.Created.Time()
+		default:
+			continue
+		}
+		if !w.inDateRange(posted) {
+			continue
+		}
+		for _, reaction := range r.Reactions {
+			for _, u := range reaction.Users {
+				if u == w.userID {
+					item.Reaction = reaction.Name
+					items = append(items, item)
+					break
+				}
+			}
+		}
+	}
+	return items
+}
+
+// PinItems returns the items that remove the pins in c that point to the user's messages or files posted in the date range.
+func (w *Wiper) PinItems(ctx context.Context, c slack.Channel) ([]Item, error) {
+	var pinned []slack.Item
+	err := w.call(ctx, "pins.list", func() (err error) {
+		pinned, _, err = w.client.ListPinsContext(ctx, c.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, p := range pinned {
+		item := Item{
+			Kind:    KindPin,
+			Action:  ActionRemove,
+			Channel: c.ID,
+		}
+		var posted time.Time
+		switch {
+		case p.Type == slack.TYPE_MESSAGE && p.Message != nil && p.Message.User == w.userID:
+			item.Timestamp = p.Message.Timestamp
+			posted = TimestampTime(item.Timestamp)
+		case p.Type == slack.TYPE_FILE && p.File != nil && p.File.User == w.userID:
+			item.File = p.File.ID
+			posted = p.File.Created.Time()
+		default:
+			continue
+		}
+		if !w.inDateRange(posted) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Stars returns all of the user's starred items (in any conversation). The list is fetched once.
+func (w *Wiper) Stars(ctx context.Context) ([]slack.Item, error) {
+	w.mu.Lock()
+	starred := w.stars
+	w.mu.Unlock()
+	if starred != nil {
+		return starred, nil
+	}
+	params := slack.NewStarsParameters()
+	params.User = w.userID
+	params.Count = 100
+	starred = []slack.Item{}
+	var starredPage []slack.Item
+	var paging *slack.Paging
+	listStars := func() (err error) {
+		starredPage, paging, err = w.client.ListStarsContext(ctx, params)
+		return err
+	}
+	pageMax := 1
+	for fetched := 1; params.Page <= pageMax; fetched++ {
+		if err := w.call(ctx, "stars.list", listStars); err != nil {
+			return nil, err
+		}
+		starred = append(starred, starredPage...)
+		if paging != nil {
+			pageMax = paging.Pages
+		}
+		params.Page++
+		w.emit(PageFetched{Kind: KindStar, Fetched: fetched, Pages: pageMax})
+	}
+	w.mu.Lock()
+	w.stars = starred
+	w.mu.Unlock()
+	return starred, nil
+}
+
+// StarItems returns the items that remove the user's stars on messages and files in c posted in the date range.
+func (w *Wiper) StarItems(c slack.Channel, starred []slack.Item) []Item {
+	var items []Item
+	for _, s := range starred {
+		item := Item{
+			Kind:    KindStar,
+			Action:  ActionRemove,
+			Channel: c.ID,
+		}
+		var posted time.Time
+		switch {
+		case s.Type == slack.TYPE_MESSAGE && s.Message != nil && s.Channel == c.ID:
+			item.Timestamp = s.Message.Timestamp
+			posted = TimestampTime(item.Timestamp)
+		case s.Type == slack.TYPE_FILE && s.File != nil && FileInChannel(*s.File, c.ID):
+			item.File = s.File.ID
+			posted = s.File.Created.Time()
+		default:
+			continue
+		}
+		if !w.inDateRange(posted) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
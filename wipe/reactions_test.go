@@ -0,0 +1,31 @@
+package wipe
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestStarItemsDateRange(t *testing.T) {
+	now := time.Now()
+	old, recent := now.Add(-48*time.Hour), now.Add(-time.Hour)
+	ts := func(t time.Time) string { return fmt.Sprintf("%d.000100", t.Unix()) }
+	file := func(id string, t time.Time) *slack.File {
+		return &slack.File{ID: id, Created: slack.JSONTime(t.Unix()), Channels: []string{"C00GENERAL"}}
+	}
+	starred := []slack.Item{
+		{Type: slack.TYPE_MESSAGE, Channel: "C00GENERAL", Message: &slack.Message{Msg: slack.Msg{Timestamp: ts(old)}}},
+		{Type: slack.TYPE_MESSAGE, Channel: "C00GENERAL", Message: &slack.Message{Msg: slack.Msg{Timestamp: ts(recent)}}},
+		{Type: slack.TYPE_FILE, File: file("F0000000OLD", old)},
+		{Type: slack.TYPE_FILE, File: file("F000000RCNT", recent)},
+	}
+	w := (&Wiper{}).With(WithDateRange(time.Time{}, now.Add(-24*time.Hour)))
+	var c slack.Channel
+	c.ID = "C00GENERAL"
+	items := w.StarItems(c, starred)
+	if len(items) != 2 || items[0].Timestamp != ts(old) || items[1].File != "F0000000OLD" {
+		t.Errorf("StarItems = %+v, want the star on the old message and the one on the old file", items)
+	}
+}
@@ -0,0 +1,227 @@
+package wipe
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode"
+
+	"github.com/nlopes/slack"
+	"golang.org/x/text/runes"
+)
+
+// A Redactor computes the text that replaces a redacted message.
+type Redactor interface {
+	Redact(text string) string
+}
+
+// RedactorFunc adapts a function to a Redactor.
+type RedactorFunc func(text string) string
+
+// Redact calls f.
+func (f RedactorFunc) Redact(text string) string { return f(text) }
+
+// Redaction styles.
+const (
+	StyleShape       = "shape"
+	StyleMask        = "mask"
+	StyleFixed       = "fixed"
+	StyleNoise       = "noise"
+	StylePlaceholder = "placeholder"
+	StyleLorem       = "lorem"
+	StyleFirstLetter = "first-letter"
+)
+
+// RedactStyles lists the styles accepted by NewRedactor.
+var RedactStyles = []string{StyleShape, StyleMask, StyleFixed, StyleNoise, StylePlaceholder, StyleLorem, StyleFirstLetter}
+
+// placeholderLength is the length of every message redacted in the placeholder style.
+const placeholderLength = 10
+
+// NewRedactor returns the Redactor for the given style.
+// Masking styles use the marker rune; the fixed style replaces every message with text.
+func NewRedactor(style string, marker rune, text string) (Redactor, error) {
+	switch style {
+	case StyleShape:
+		return ShapeRedactor(marker), nil
+	case StyleMask:
+		return MaskRedactor(marker), nil
+	case StyleFixed:
+		return FixedRedactor(text), nil
+	case StyleNoise:
+		return NoiseRedactor(), nil
+	case StylePlaceholder:
+		return FixedRedactor(strings.Repeat(string(marker), placeholderLength)), nil
+	case StyleLorem:
+		return LoremRedactor(), nil
+	case StyleFirstLetter:
+		return FirstLetterRedactor(marker), nil
+	}
+	return nil, fmt.Errorf("unknown redaction style %q (expected one of %s)", style, strings.Join(RedactStyles, ", "))
+}
+
+// ShapeRedactor replaces all runes except spaces and punctuation with marker, so the shape of the words remains.
+func ShapeRedactor(marker rune) Redactor {
+	return RedactorFunc(runes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return r
+		}
+		return marker
+	}).String)
+}
+
+// MaskRedactor replaces every rune (except line breaks) with marker, so only the length remains.
+func MaskRedactor(marker rune) Redactor {
+	return RedactorFunc(runes.Map(func(r rune) rune {
+		if r == '\n' {
+			return r
+		}
+		return marker
+	}).String)
+}
+
+// FixedRedactor replaces every message with the same text.
+func FixedRedactor(text string) Redactor {
+	return RedactorFunc(func(string) string { return text })
+}
+
+// NoiseRedactor replaces letters and digits with random ones of the same kind:
+// ASCII lower and upper case letters and digits stay in their range, other letters are drawn from those in the message.
+func NoiseRedactor() Redactor {
+	return RedactorFunc(func(text string) string {
+		var lower, upper, other []rune
+		for _, r := range text {
+			switch {
+			case r < unicode.MaxASCII:
+			case unicode.IsLower(r):
+				lower = append(lower, r)
+			case unicode.IsUpper(r):
+				upper = append(upper, r)
+			case unicode.IsLetter(r):
+				other = append(other, r)
+			}
+		}
+		return strings.Map(func(r rune) rune {
+			switch {
+			case 'a' <= r && r <= 'z':
+				return 'a' + rune(rand.Intn(26))
+			case 'A' <= r && r <= 'Z':
+				return 'A' + rune(rand.Intn(26))
+			case '0' <= r && r <= '9':
+				return '0' + rune(rand.Intn(10))
+			case r < unicode.MaxASCII:
+				return r
+			case unicode.IsLower(r):
+				return lower[rand.Intn(len(lower))]
+			case unicode.IsUpper(r):
+				return upper[rand.Intn(len(upper))]
+			case unicode.IsLetter(r):
+				return other[rand.Intn(len(other))]
+			}
+			return r
+		}, text)
+	})
+}
+
+var loremWords = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor
+	incididunt ut labore et dolore magna aliqua ut enim ad minim veniam quis nostrud exercitation ullamco
+	laboris nisi ut aliquip ex ea commodo consequat duis aute irure dolor in reprehenderit in voluptate
+	velit esse cillum dolore eu fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt
+	in culpa qui officia deserunt mollit anim id est laborum`)
+
+// LoremRedactor replaces each word with the next word of the lorem ipsum text, keeping spaces and punctuation.
+func LoremRedactor() Redactor {
+	return RedactorFunc(func(text string) string {
+		n := 0
+		return mapWords(text, func(word string) string {
+			lorem := loremWords[n%len(loremWords)]
+			n++
+			return lorem
+		})
+	})
+}
+
+// FirstLetterRedactor keeps the first letter of each word and replaces the rest with marker.
+func FirstLetterRedactor(marker rune) Redactor {
+	return RedactorFunc(func(text string) string {
+		return mapWords(text, func(word string) string {
+			first := []rune(word)[0]
+			return string(first) + strings.Repeat(string(marker), len([]rune(word))-1)
+		})
+	})
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// mapWords replaces each word (a run of letters and digits) of text with f(word).
+func mapWords(text string, f func(word string) string) string {
+	var b strings.Builder
+	start := -1
+	for i, r := range text {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			b.WriteString(f(text[start:i]))
+			start = -1
+			fallthrough
+		case !isWordRune(r):
+			b.WriteRune(r)
+		}
+	}
+	if start >= 0 {
+		b.WriteString(f(text[start:]))
+	}
+	return b.String()
+}
+
+// redactAttachments returns the attachments with their text fields redacted and their links, images and buttons removed.
+// Link previews are dropped unless the Wiper keeps unfurls.
+// The result is never nil, so that an update replaces the original attachments even if none remain.
+func (w *Wiper) redactAttachments(attachments []slack.Attachment) []slack.Attachment {
+	redacted := []slack.Attachment{}
+	for _, a := range attachments {
+		if isUnfurl(a) && !w.keepUnfurls {
+			continue
+		}
+		r := slack.Attachment{
+			Color:         a.Color,
+			Fallback:      w.redactField(a.Fallback),
+			AuthorName:    w.redactField(a.AuthorName),
+			AuthorSubname: w.redactField(a.AuthorSubname),
+			Title:         w.redactField(a.Title),
+			Pretext:       w.redactField(a.Pretext),
+			Text:          w.redactField(a.Text),
+			MarkdownIn:    a.MarkdownIn,
+			Footer:        w.redactField(a.Footer),
+		}
+		for _, f := range a.Fields {
+			r.Fields = append(r.Fields, slack.AttachmentField{
+				Title: w.redactField(f.Title),
+				Value: w.redactField(f.Value),
+				Short: f.Short,
+			})
+		}
+		redacted = append(redacted, r)
+	}
+	return redacted
+}
+
+// redactField redacts non-empty text, so that the fixed styles do not fill in unused fields.
+func (w *Wiper) redactField(text string) string {
+	if text == "" {
+		return ""
+	}
+	return w.redact.Redact(text)
+}
+
+// isUnfurl reports whether the attachment looks like a link preview: it points to a link or an image,
+// and has none of the fields, buttons or callbacks of an app's attachment.
+func isUnfurl(a slack.Attachment) bool {
+	if a.CallbackID != "" || len(a.Actions) > 0 || len(a.Fields) > 0 {
+		return false
+	}
+	return a.TitleLink != "" || a.ImageURL != "" || a.ThumbURL != ""
+}
@@ -0,0 +1,88 @@
+package wipe
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestRedactStyles(t *testing.T) {
+	tests := []struct {
+		style string
+		text  string
+		want  string
+	}{
+		{StyleShape, "Hello, wörld! 42\nbye", "█████, █████! ██\n███"},
+		{StyleShape, "", ""},
+		{StyleShape, "ship it (today)", "████ ██ (█████)"},
+		{StyleMask, "Hello, wörld! 42\nbye", "████████████████\n███"},
+		{StyleMask, "a b", "███"},
+		{StyleFixed, "Hello, wörld! 42\nbye", "[redacted]"},
+		{StyleFixed, "", "[redacted]"},
+		{StylePlaceholder, "Hello, wörld! 42\nbye", "██████████"},
+		{StylePlaceholder, "hi", "██████████"},
+		{StyleLorem, "Hello, wörld! 42\nbye", "lorem, ipsum! dolor\nsit"},
+		{StyleLorem, "don't", "lorem'ipsum"},
+		{StyleFirstLetter, "Hello, wörld! 42\nbye", "H████, w████! 4█\nb██"},
+		{StyleFirstLetter, "Ärger über x", "Ä████ ü███ x"},
+	}
+	for _, test := range tests {
+		r, err := NewRedactor(test.style, '█', "[redacted]")
+		if err != nil {
+			t.Fatalf("NewRedactor(%q): %v", test.style, err)
+		}
+		if got := r.Redact(test.text); got != test.want {
+			t.Errorf("%s: Redact(%q) = %q, want %q", test.style, test.text, got, test.want)
+		}
+	}
+}
+
+func TestNoiseRedactor(t *testing.T) {
+	class := func(r rune) string {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return "lower"
+		case 'A' <= r && r <= 'Z':
+			return "upper"
+		case '0' <= r && r <= '9':
+			return "digit"
+		case r < unicode.MaxASCII:
+			return string(r)
+		case unicode.IsLower(r):
+			return "other lower"
+		case unicode.IsUpper(r):
+			return "other upper"
+		case unicode.IsLetter(r):
+			return "other letter"
+		}
+		return string(r)
+	}
+	r, err := NewRedactor(StyleNoise, '█', "")
+	if err != nil {
+		t.Fatalf("NewRedactor: %v", err)
+	}
+	for _, text := range []string{"", "Hello, World! 42", "Grüße aus Köln, ÄÖÜ", "日本語 ok\n:tada:"} {
+		for i := 0; i < 20; i++ {
+			got := r.Redact(text)
+			want, have := []rune(text), []rune(got)
+			if len(have) != len(want) {
+				t.Fatalf("Redact(%q) = %q, want %d runes", text, got, len(want))
+			}
+			for j := range want {
+				if class(have[j]) != class(want[j]) {
+					t.Fatalf("Redact(%q) = %q: %q at %d is not of the same kind as %q", text, got, have[j], j, want[j])
+				}
+			}
+		}
+	}
+	if text := "the quick brown fox jumps over the lazy dog"; r.Redact(text) == text {
+		t.Errorf("Redact(%q) did not change the text", text)
+	}
+}
+
+func TestNewRedactorUnknownStyle(t *testing.T) {
+	_, err := NewRedactor("blur", '█', "")
+	if err == nil || !strings.Contains(err.Error(), "blur") {
+		t.Errorf("NewRedactor(blur): err = %v, want an unknown style error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// CallTimeout bounds a single wipe request.
+const CallTimeout = 30 * time.Second
+
+type job struct {
+	seq  int
+	item Item
+}
+
+type result struct {
+	job
+	attempts int
+	err      error
+}
+
+// Run wipes the items received from source on the configured number of workers, retrying transient errors.
+// An ItemWiped event is emitted for each item, in the order the items were received.
+// Once ctx is done, no further items are dispatched or requested (items waiting for the rate limit are not attempted,
+// and no ItemWiped event is emitted for them); requests already in flight are allowed to finish (or time out).
+// Run returns the number of items that were wiped and that failed.
+func (w *Wiper) Run(ctx context.Context, source <-chan Item) (done, failed int) {
+	jobs := make(chan job)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				attempts, err := w.execute(ctx, j.item)
+				results <- result{job: j, attempts: attempts, err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for seq := 0; ctx.Err() == nil; seq++ {
+			var item Item
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case item, ok = <-source:
+				if !ok {
+					return
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- job{seq: seq, item: item}:
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	pending := make(map[int]result)
+	next := 0
+	for r := range results {
+		pending[r.seq] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if r.attempts == 0 && r.err != nil {
+				// ctx was done before the item's request was made: the item was not attempted.
+				continue
+			}
+			w.emit(ItemWiped{Item: r.item, Attempts: r.attempts, Err: r.err})
+			if r.err != nil {
+				failed++
+			} else {
+				done++
+			}
+		}
+	}
+	return done, failed
+}
+
+// execute wipes the item, retrying transient errors. Once ctx is done, no further requests are made,
+// but the request in flight is allowed to finish. If no request was made, attempts is zero.
+func (w *Wiper) execute(ctx context.Context, item Item) (int, error) {
+	if item.Kind == KindMessage && item.Action == ActionOverwrite {
+		return w.overwrite(ctx, item)
+	}
+	return attempt(ctx, func() error { return w.wipe(ctx, context.Background(), item) })
+}
+
+// Wipe executes a single item (once, without retries), waiting for the method's rate limit first.
+// The request itself is bounded by CallTimeout. The phases of an overwrite are executed in turn,
+// and a failure is returned as a *PhaseError.
+func (w *Wiper) Wipe(ctx context.Context, item Item) error {
+	return w.wipe(ctx, ctx, item)
+}
+
+// wipe is Wipe, waiting for the rate limit until waitCtx is done and making the request with ctx.
+func (w *Wiper) wipe(waitCtx, ctx context.Context, item Item) error {
+	if item.Kind == KindMessage && item.Action == ActionOverwrite {
+		for _, phase := range overwritePhases {
+			err := w.overwritePhase(waitCtx, ctx, item, phase)
+			if goneErrors[ErrorCode(err)] {
+				return nil
+			}
+			if err != nil {
+				return &PhaseError{Phase: phase, Err: err}
+			}
+		}
+		return nil
+	}
+	var method string
+	var wipe func(ctx context.Context) error
+	switch {
+	case item.Kind == KindMessage && item.Action == ActionDelete:
+		method = "chat.delete"
+		wipe = func(ctx context.Context) error {
+			_, _, err := w.client.DeleteMessageContext(ctx, item.Channel, item.Timestamp)
+			return err
+		}
+	case item.Kind == KindMessage && item.Action == ActionRedact:
+		method = "chat.update"
+		wipe = func(ctx context.Context) error {
+			return w.update(ctx, item)
+		}
+	case item.Kind == KindFile && item.Action == ActionDelete:
+		method = "files.delete"
+		wipe = func(ctx context.Context) error {
+			return w.client.DeleteFileContext(ctx, item.File)
+		}
+	case item.Kind == KindReaction && item.Action == ActionRemove:
+		method = "reactions.remove"
+		wipe = func(ctx context.Context) error {
+			return w.client.RemoveReactionContext(ctx, item.Reaction, item.ref())
+		}
+	case item.Kind == KindPin && item.Action == ActionRemove:
+		method = "pins.remove"
+		wipe = func(ctx context.Context) error {
+			return w.client.RemovePinContext(ctx, item.Channel, item.ref())
+		}
+	case item.Kind == KindStar && item.Action == ActionRemove:
+		method = "stars.remove"
+		wipe = func(ctx context.Context) error {
+			return w.client.RemoveStarContext(ctx, item.Channel, item.ref())
+		}
+	default:
+		return fmt.Errorf("unsupported item: %s %s", item.Action, item.Kind)
+	}
+	return w.call(waitCtx, method, func() error {
+		ctx, cancel := context.WithTimeout(ctx, CallTimeout)
+		defer cancel()
+		return wipe(ctx)
+	})
+}
+
+// update replaces the message's text with the redacted text, its attachments with the redacted attachments, and removes its blocks.
+func (w *Wiper) update(ctx context.Context, item Item) error {
+	attachments := item.Attachments
+	if attachments == nil {
+		attachments = []slack.Attachment{}
+	}
+	_, _, _, err := w.client.SendMessageContext(ctx, item.Channel,
+		slack.MsgOptionUpdate(item.Timestamp),
+		// the redacted text is derived from the message's markup, which is already escaped.
+		slack.MsgOptionText(item.Redacted, false),
+		slack.MsgOptionAttachments(attachments...),
+		// the client cannot send blocks; an empty list removes the message's blocks.
+		slack.UnsafeMsgOptionEndpoint(slack.SLACK_API+"chat.update", func(values url.Values) {
+			values.Set("blocks", "[]")
+		}),
+	)
+	return err
+}
@@ -0,0 +1,196 @@
+package wipe
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+	"github.com/sgreben/slack-wipe/fakeslack"
+)
+
+const (
+	testToken = "xoxp-test"
+	testUser  = "U00000TEST"
+	otherUser = "U000000BOB"
+)
+
+func TestMain(m *testing.M) {
+	// the fake workspace has no rate limits of its own (except injected ones)
+	for tier := range rateLimitTiers {
+		rateLimitTiers[tier] = 60000
+	}
+	os.Exit(m.Run())
+}
+
+// newWorkspace starts a fake workspace with a channel and an IM, each with two messages by the user and one by someone else.
+func newWorkspace(t *testing.T) *fakeslack.Server {
+	s := fakeslack.New(testToken, testUser)
+	t.Cleanup(s.Close)
+	slack.SLACK_API = s.APIURL()
+	s.AddUser(testUser, "me")
+	s.AddUser(otherUser, "bob")
+	s.AddChannel("C00GENERAL", "general", false, testUser, otherUser)
+	s.AddIM("D000000BOB", otherUser)
+	posted := time.Now().Add(-time.Hour)
+	for _, c := range []string{"C00GENERAL", "D000000BOB"} {
+		s.Post(c, testUser, "first secret", posted, "")
+		s.Post(c, otherUser, "not mine", posted.Add(time.Second), "")
+		s.Post(c, testUser, "second secret", posted.Add(2*time.Second), "")
+	}
+	return s
+}
+
+type testRun struct {
+	mu     sync.Mutex
+	wiped  []ItemWiped
+	limits []RateLimited
+}
+
+func (r *testRun) event(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e := e.(type) {
+	case ItemWiped:
+		r.wiped = append(r.wiped, e)
+	case RateLimited:
+		r.limits = append(r.limits, e)
+	}
+}
+
+// wipeConversation lists the user's messages in the conversation and runs them.
+func wipeConversation(t *testing.T, channel string, options ...Option) (*testRun, int, int) {
+	t.Helper()
+	ctx := context.Background()
+	r := &testRun{}
+	options = append([]Option{WithToken(testToken), WithTargets(Targets{Messages: true}), WithConcurrency(2), WithEvents(r.event)}, options...)
+	w, err := New(ctx, options...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var c slack.Channel
+	if strings.HasPrefix(channel, "D") {
+		if c, err = w.ResolveIM(ctx, []string{otherUser}); err != nil {
+			t.Fatalf("ResolveIM: %v", err)
+		}
+	} else if c, err = w.ResolveChannel(ctx, channel); err != nil {
+		t.Fatalf("ResolveChannel: %v", err)
+	}
+	items, err := w.Items(ctx, c)
+	if err != nil {
+		t.Fatalf("Items: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2: %+v", len(items), items)
+	}
+	source := make(chan Item, len(items))
+	for _, item := range items {
+		source <- item
+	}
+	close(source)
+	done, failed := w.Run(ctx, source)
+	return r, done, failed
+}
+
+func texts(messages []slack.Message) []string {
+	var texts []string
+	for _, m := range messages {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+func TestRunDelete(t *testing.T) {
+	for _, c := range []struct{ name, id string }{{"general", "C00GENERAL"}, {"D000000BOB", "D000000BOB"}} {
+		s := newWorkspace(t)
+		_, done, failed := wipeConversation(t, c.name)
+		if done != 2 || failed != 0 {
+			t.Errorf("%s: done, failed = %d, %d, want 2, 0", c.name, done, failed)
+		}
+		if got := texts(s.Messages(c.id)); len(got) != 1 || got[0] != "not mine" {
+			t.Errorf("%s: messages left = %q, want only the other user's", c.name, got)
+		}
+	}
+}
+
+func TestRunRedact(t *testing.T) {
+	s := newWorkspace(t)
+	_, done, failed := wipeConversation(t, "D000000BOB", WithRedact(FixedRedactor("[redacted]")))
+	if done != 2 || failed != 0 {
+		t.Errorf("done, failed = %d, %d, want 2, 0", done, failed)
+	}
+	want := []string{"[redacted]", "not mine", "[redacted]"}
+	if got := texts(s.Messages("D000000BOB")); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
+
+func TestRunRetriesRateLimits(t *testing.T) {
+	s := newWorkspace(t)
+	s.RateLimit("chat.delete", time.Second, 1)
+	r, done, failed := wipeConversation(t, "general")
+	if done != 2 || failed != 0 {
+		t.Errorf("done, failed = %d, %d, want 2, 0", done, failed)
+	}
+	if len(r.limits) != 1 || r.limits[0].Method != "chat.delete" || r.limits[0].RetryAfter != time.Second {
+		t.Errorf("rate limit events = %+v, want one for chat.delete, retrying after 1s", r.limits)
+	}
+	if n := s.Calls("chat.delete"); n != 3 {
+		t.Errorf("chat.delete was called %d times, want 3", n)
+	}
+}
+
+func TestRunRetriesTransientErrors(t *testing.T) {
+	s := newWorkspace(t)
+	s.Fail("chat.delete", "internal_error", 1)
+	r, done, failed := wipeConversation(t, "general")
+	if done != 2 || failed != 0 {
+		t.Errorf("done, failed = %d, %d, want 2, 0", done, failed)
+	}
+	attempts := 0
+	for _, e := range r.wiped {
+		attempts += e.Attempts
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+}
+
+func TestRunReportsErrors(t *testing.T) {
+	s := newWorkspace(t)
+	s.Fail("chat.delete", "cant_delete_message", 1)
+	r, done, failed := wipeConversation(t, "general")
+	if done != 1 || failed != 1 {
+		t.Errorf("done, failed = %d, %d, want 1, 1", done, failed)
+	}
+	for _, e := range r.wiped {
+		if e.Err != nil && (ErrorCode(e.Err) != "cant_delete_message" || e.Attempts != 1) {
+			t.Errorf("error %v (code %q) after %d attempts, want cant_delete_message after 1", e.Err, ErrorCode(e.Err), e.Attempts)
+		}
+	}
+	if got := texts(s.Messages("C00GENERAL")); len(got) != 2 {
+		t.Errorf("messages left = %q, want the other user's and the failed one", got)
+	}
+}
+
+func TestWithSharesRateLimiter(t *testing.T) {
+	newWorkspace(t)
+	w, err := New(context.Background(), WithToken(testToken))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	after := time.Now().Add(-time.Minute)
+	c := w.With(WithDateRange(after, time.Time{}), WithTargets(Targets{Messages: true}))
+	if c.limiter != w.limiter {
+		t.Error("With: the copy has its own rate limiter")
+	}
+	if !c.after.Equal(after) || !c.targets.Messages || w.targets.Messages || !w.after.IsZero() {
+		t.Error("With: the options were not applied to the copy only")
+	}
+	if c.UserID() != testUser {
+		t.Errorf("With: UserID = %q, want %q", c.UserID(), testUser)
+	}
+}
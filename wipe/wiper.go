@@ -0,0 +1,232 @@
+// Package wipe finds and wipes a Slack user's own messages, files, reactions, pins and stars.
+//
+// A Wiper is built from options, resolves conversations, lists the candidate items in them,
+// and executes the items, reporting progress as typed events.
+package wipe
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// Targets selects what Items lists.
+type Targets struct {
+	Messages  bool
+	Files     bool
+	Reactions bool
+	Pins      bool
+	Stars     bool
+}
+
+// A Wiper wipes the items of the user its client's token belongs to.
+type Wiper struct {
+	client      *slack.Client
+	targets     Targets
+	after       time.Time
+	before      time.Time
+	oldest      time.Time
+	match       *regexp.Regexp
+	exclude     *regexp.Regexp
+	redact      Redactor
+	overwriting bool
+	keepUnfurls bool
+	concurrency int
+	events      func(Event)
+	limiter     *rateLimiter
+
+	user    string
+	userID  string
+	teamURL string
+
+	mu        sync.Mutex
+	users     []slack.User
+	usersByID map[string]slack.User
+	reactions []slack.ReactedItem
+	stars     []slack.Item
+}
+
+// An Option configures a Wiper.
+type Option func(*Wiper)
+
+// WithClient sets the Slack client.
+func WithClient(client *slack.Client) Option {
+	return func(w *Wiper) { w.client = client }
+}
+
+// WithToken creates a Slack client for the given API token.
+func WithToken(token string) Option {
+	return func(w *Wiper) { w.client = slack.New(token) }
+}
+
+// WithTargets selects what Items lists.
+func WithTargets(targets Targets) Option {
+	return func(w *Wiper) { w.targets = targets }
+}
+
+// WithDateRange restricts messages and files to those posted in [after, before), and reactions, pins and stars
+// to those on messages and files posted in that range. Zero times are unbounded.
+func WithDateRange(after, before time.Time) Option {
+	return func(w *Wiper) { w.after, w.before = after, before }
+}
+
+// WithOldest stops reading IM and group IM history at messages posted before t, including threads whose parent was.
+// Unlike WithDateRange's after, which has to read the whole history to find recent replies in old threads,
+// it bounds the number of requests; replies in threads started before t are missed.
+func WithOldest(t time.Time) Option {
+	return func(w *Wiper) { w.oldest = t }
+}
+
+// WithMatch restricts messages to those whose text or attachment text matches re.
+func WithMatch(re *regexp.Regexp) Option {
+	return func(w *Wiper) { w.match = re }
+}
+
+// WithExclude skips messages whose text or attachment text matches re.
+func WithExclude(re *regexp.Regexp) Option {
+	return func(w *Wiper) { w.exclude = re }
+}
+
+// WithRedact makes message items redact (using the given Redactor) instead of delete.
+func WithRedact(redact Redactor) Option {
+	return func(w *Wiper) { w.redact = redact }
+}
+
+// WithOverwrite makes message items overwrite (using the given Redactor), confirm the edit, and then delete,
+// so that nothing that mirrors the latest revision of a message keeps the original text.
+func WithOverwrite(redact Redactor) Option {
+	return func(w *Wiper) { w.redact, w.overwriting = redact, true }
+}
+
+// WithUnfurls sets whether redacted messages keep (the redacted text of) their link previews,
+// instead of losing them completely.
+func WithUnfurls(keep bool) Option {
+	return func(w *Wiper) { w.keepUnfurls = keep }
+}
+
+// WithConcurrency sets the number of concurrent wipe requests made by Run.
+func WithConcurrency(n int) Option {
+	return func(w *Wiper) { w.concurrency = n }
+}
+
+// WithEvents sets the handler for progress events. It may be called concurrently.
+func WithEvents(handler func(Event)) Option {
+	return func(w *Wiper) { w.events = handler }
+}
+
+// New builds a Wiper and looks up the user the client's token belongs to.
+func New(ctx context.Context, options ...Option) (*Wiper, error) {
+	w := &Wiper{concurrency: 1}
+	for _, option := range options {
+		option(w)
+	}
+	if w.client == nil {
+		return nil, errors.New("no Slack client or token")
+	}
+	if w.concurrency < 1 {
+		return nil, errors.New("concurrency must be at least 1")
+	}
+	w.limiter = newRateLimiter(w.emit)
+	var identity *slack.AuthTestResponse
+	err := w.call(ctx, "auth.test", func() (err error) {
+		identity, err = w.client.AuthTestContext(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.user = identity.User
+	w.userID = identity.UserID
+	w.teamURL = identity.URL
+	return w, nil
+}
+
+// With returns a copy of the Wiper with the given options applied on top of its own. The copy shares the Wiper's
+// rate limiter (and so its budget and pauses, whose events go to the Wiper's handler) and its list of users,
+// but lists reactions and stars afresh.
+func (w *Wiper) With(options ...Option) *Wiper {
+	c := &Wiper{
+		client:      w.client,
+		targets:     w.targets,
+		after:       w.after,
+		before:      w.before,
+		oldest:      w.oldest,
+		match:       w.match,
+		exclude:     w.exclude,
+		redact:      w.redact,
+		overwriting: w.overwriting,
+		keepUnfurls: w.keepUnfurls,
+		concurrency: w.concurrency,
+		events:      w.events,
+		limiter:     w.limiter,
+		user:        w.user,
+		userID:      w.userID,
+		teamURL:     w.teamURL,
+	}
+	w.mu.Lock()
+	c.users, c.usersByID = w.users, w.usersByID
+	w.mu.Unlock()
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Client returns the Slack client.
+func (w *Wiper) Client() *slack.Client { return w.client }
+
+// User returns the name of the user.
+func (w *Wiper) User() string { return w.user }
+
+// UserID returns the ID of the user.
+func (w *Wiper) UserID() string { return w.userID }
+
+// Redacting reports whether message items are redacted (or overwritten) instead of deleted.
+func (w *Wiper) Redacting() bool { return w.redact != nil }
+
+// Items lists the candidate items in the conversation, for the configured targets, in the order of Steps.
+// Run starts the items in the order it receives them, but runs several at a time; to finish each step
+// before the next one starts, run the items of each step separately.
+func (w *Wiper) Items(ctx context.Context, c slack.Channel) ([]Item, error) {
+	var items []Item
+	if w.targets.Pins {
+		pins, err := w.PinItems(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, pins...)
+	}
+	if w.targets.Stars {
+		starred, err := w.Stars(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, w.StarItems(c, starred)...)
+	}
+	if w.targets.Reactions {
+		reacted, err := w.Reactions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, w.ReactionItems(c, reacted)...)
+	}
+	if w.targets.Messages {
+		messages, err := w.Messages(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, w.MessageItems(c, messages)...)
+	}
+	if w.targets.Files {
+		files, err := w.Files(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, FileItems(c, files)...)
+	}
+	return items, nil
+}